@@ -0,0 +1,216 @@
+package tarski
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The extended attributes holding POSIX ACLs and the PAX records GNU tar and
+// star store their text form in.
+const (
+	xattrACLAccess  = "system.posix_acl_access"
+	xattrACLDefault = "system.posix_acl_default"
+	paxACLAccess    = "SCHILY.acl.access"
+	paxACLDefault   = "SCHILY.acl.default"
+)
+
+// aclRecords maps the POSIX ACL extended attributes to their PAX records.
+var aclRecords = map[string]string{
+	xattrACLAccess:  paxACLAccess,
+	xattrACLDefault: paxACLDefault,
+}
+
+// Binary POSIX ACL format as defined by struct posix_acl_xattr_header and
+// struct posix_acl_xattr_entry in the kernel.
+const (
+	aclVersion     = 2
+	aclHeaderSize  = 4
+	aclEntrySize   = 8
+	aclUndefinedID = 0xffffffff
+
+	aclUserObj  = 0x01
+	aclUser     = 0x02
+	aclGroupObj = 0x04
+	aclGroup    = 0x08
+	aclMask     = 0x10
+	aclOther    = 0x20
+)
+
+var aclTags = map[uint16]string{
+	aclUserObj:  "user",
+	aclUser:     "user",
+	aclGroupObj: "group",
+	aclGroup:    "group",
+	aclMask:     "mask",
+	aclOther:    "other",
+}
+
+// encodeACLs moves the POSIX ACLs found in the extended attributes of h to the
+// SCHILY.acl PAX records in text form.
+func encodeACLs(h *tar.Header) error {
+	for attr, record := range aclRecords {
+		value, ok := h.Xattrs[attr]
+		if !ok {
+			continue
+		}
+
+		text, err := aclToText([]byte(value))
+		if err != nil {
+			return fmt.Errorf("%s: %w", attr, err)
+		}
+
+		if h.PAXRecords == nil {
+			h.PAXRecords = make(map[string]string)
+		}
+		h.PAXRecords[record] = text
+		delete(h.Xattrs, attr)
+	}
+
+	return nil
+}
+
+// decodeACLs restores the POSIX ACLs stored in the SCHILY.acl PAX records of h
+// as extended attributes.
+func decodeACLs(h *tar.Header) error {
+	for attr, record := range aclRecords {
+		text, ok := h.PAXRecords[record]
+		if !ok || text == "" {
+			continue
+		}
+
+		value, err := aclFromText(text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", record, err)
+		}
+
+		if h.Xattrs == nil {
+			h.Xattrs = make(map[string]string)
+		}
+		h.Xattrs[attr] = string(value)
+	}
+
+	return nil
+}
+
+// aclToText converts a binary POSIX ACL to its short text form with numeric
+// ids, e.g. "user::rw-,user:1000:r--,group::r--,mask::r--,other::r--".
+func aclToText(acl []byte) (string, error) {
+	if len(acl) < aclHeaderSize || (len(acl)-aclHeaderSize)%aclEntrySize != 0 {
+		return "", fmt.Errorf("Invalid ACL of %d bytes.", len(acl))
+	}
+	if v := binary.LittleEndian.Uint32(acl); v != aclVersion {
+		return "", fmt.Errorf("Unsupported ACL version %d.", v)
+	}
+
+	var entries []string
+	for b := acl[aclHeaderSize:]; len(b) > 0; b = b[aclEntrySize:] {
+		tag := binary.LittleEndian.Uint16(b)
+		perm := binary.LittleEndian.Uint16(b[2:])
+		id := binary.LittleEndian.Uint32(b[4:])
+
+		name, ok := aclTags[tag]
+		if !ok {
+			return "", fmt.Errorf("Unknown ACL tag %#x.", tag)
+		}
+
+		qualifier := ""
+		if tag == aclUser || tag == aclGroup {
+			qualifier = strconv.FormatUint(uint64(id), 10)
+		}
+
+		entries = append(entries, name+":"+qualifier+":"+aclPermText(perm))
+	}
+
+	return strings.Join(entries, ","), nil
+}
+
+func aclPermText(perm uint16) string {
+	b := []byte("---")
+	for i, c := range "rwx" {
+		if perm&(4>>i) != 0 {
+			b[i] = byte(c)
+		}
+	}
+
+	return string(b)
+}
+
+// aclFromText converts the text form of a POSIX ACL to its binary form. Entries
+// are separated by commas or newlines. Qualifiers need to be numeric ids or be
+// followed by the numeric id as in the star format "user:name:rw-:1000".
+func aclFromText(text string) ([]byte, error) {
+	acl := binary.LittleEndian.AppendUint32(nil, aclVersion)
+
+	for _, entry := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		fields := strings.Split(strings.TrimSpace(entry), ":")
+		if len(fields) < 3 || len(fields) > 4 {
+			return nil, fmt.Errorf("Invalid ACL entry %q.", entry)
+		}
+
+		qualifier := fields[1]
+		if len(fields) == 4 {
+			qualifier = fields[3]
+		}
+
+		var tag uint16
+		id := uint64(aclUndefinedID)
+		switch fields[0] {
+		case "user", "u":
+			tag = aclUserObj
+			if qualifier != "" {
+				tag = aclUser
+			}
+		case "group", "g":
+			tag = aclGroupObj
+			if qualifier != "" {
+				tag = aclGroup
+			}
+		case "mask", "m":
+			tag = aclMask
+		case "other", "o":
+			tag = aclOther
+		default:
+			return nil, fmt.Errorf("Invalid ACL entry %q.", entry)
+		}
+
+		if tag == aclUser || tag == aclGroup {
+			var err error
+			id, err = strconv.ParseUint(qualifier, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid ACL entry %q.", entry)
+			}
+		}
+
+		perm, err := aclPermFromText(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ACL entry %q.", entry)
+		}
+
+		acl = binary.LittleEndian.AppendUint16(acl, tag)
+		acl = binary.LittleEndian.AppendUint16(acl, perm)
+		acl = binary.LittleEndian.AppendUint32(acl, uint32(id))
+	}
+
+	return acl, nil
+}
+
+func aclPermFromText(text string) (perm uint16, err error) {
+	for _, c := range text {
+		switch c {
+		case 'r':
+			perm |= 4
+		case 'w':
+			perm |= 2
+		case 'x':
+			perm |= 1
+		case '-':
+		default:
+			return 0, fmt.Errorf("Invalid ACL permissions %q.", text)
+		}
+	}
+
+	return
+}
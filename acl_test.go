@@ -0,0 +1,90 @@
+package tarski
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestACLText(t *testing.T) {
+	text := "user::rw-,user:1000:r--,group::r--,group:1001:rwx,mask::rwx,other::---"
+
+	acl, err := aclFromText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acl) != aclHeaderSize+6*aclEntrySize {
+		t.Fatalf("Expected %d entries. Received %d bytes instead.", 6, len(acl))
+	}
+
+	found, err := aclToText(acl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != text {
+		t.Fatalf("Expected %q. Received %q instead.", text, found)
+	}
+
+	star, err := aclFromText("user::rw-\nuser:joe:r--:1000\ngroup::r--\ngroup:wheel:rwx:1001\nmask::rwx\nother::---")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(star, acl) {
+		t.Fatalf("Expected the star format to yield %x. Received %x instead.", acl, star)
+	}
+
+	for _, invalid := range []string{"user", "user:joe:r--", "nobody::rwx", "other::rwz"} {
+		if _, err = aclFromText(invalid); err == nil {
+			t.Fatalf("Expected %q to be rejected.", invalid)
+		}
+	}
+	if _, err = aclToText([]byte{2, 0, 0}); err == nil {
+		t.Fatalf("Expected a truncated ACL to be rejected.")
+	}
+}
+
+func TestACLPAXEncoding(t *testing.T) {
+	src := t.TempDir()
+	file := filepath.Join(src, "file")
+	if err := os.WriteFile(file, []byte("content"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	text := "user::rw-,user:1000:rw-,group::r--,mask::rw-,other::---"
+	acl, err := aclFromText(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = SetXattr(file, xattrACLAccess, acl); err != nil {
+		t.Skipf("POSIX ACLs are not supported: %v", err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err = Create(tarball, src, src, WithACLPAXEncoding()); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ExtractPAXRecords(tarball, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[paxACLAccess] != text {
+		t.Fatalf("Expected %s to be %q. Received %q instead.", paxACLAccess, text, records[paxACLAccess])
+	}
+	if _, ok := records["SCHILY.xattr."+xattrACLAccess]; ok {
+		t.Fatalf("Expected the binary ACL not to be archived.")
+	}
+
+	dest := t.TempDir()
+	if err = Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	found, err := GetXattr(filepath.Join(dest, "file"), xattrACLAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(found, acl) {
+		t.Fatalf("Expected ACL %x. Received %x instead.", acl, found)
+	}
+}
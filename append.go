@@ -0,0 +1,136 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Append adds the files found under path to the end of the existing tar
+// archive. The end-of-archive marker is overwritten by the new entries and
+// written again after them. Empty archives are created from scratch as if by
+// Create. Compressed archives cannot be appended to and ErrAppendCompressed is
+// returned for them. ErrTruncatedArchive is returned for archives without an
+// end-of-archive marker.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func Append(archive string, path string, prefix string, opts ...Option) error {
+	return appendArchive(archive, path, prefix, nil, newOptions(opts))
+}
+
+// AppendSHA256 appends to an archive like Append and returns the SHA256-hash
+// checksum of the resulting tar stream. It covers both the existing and the
+// appended entries and matches the checksum CreateSHA256 would have returned
+// for an archive holding all of them.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func AppendSHA256(archive string, path string, prefix string, opts ...Option) (checksum []byte, err error) {
+	b := sha256.New()
+	if err = appendArchive(archive, path, prefix, b, newOptions(opts)); err != nil {
+		return
+	}
+
+	return b.Sum(nil), nil
+}
+
+// appendArchive appends the files found under path to archive. If h is not nil
+// the existing entries and the appended tar stream are written to it.
+func appendArchive(archive string, path string, prefix string, h io.Writer, o *Options) (err error) {
+	c, _, err := DetectFormat(archive)
+	if err != nil {
+		return
+	}
+	if c != CompressionNone || o.Compression != CompressionNone {
+		return fmt.Errorf("%s: %w", archive, ErrAppendCompressed)
+	}
+
+	empty, err := IsEmpty(archive)
+	if err != nil {
+		return
+	}
+	if empty {
+		return createArchive(archive, path, prefix, h, o)
+	}
+
+	unlock, err := lockArchive(archive)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(archive, os.O_RDWR, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	end, err := archiveEnd(f)
+	if err != nil {
+		return
+	}
+
+	if h != nil {
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		if _, err = io.CopyN(h, f, end); err != nil {
+			return
+		}
+	}
+
+	if _, err = f.Seek(end, io.SeekStart); err != nil {
+		return
+	}
+
+	if err = createStream(f, path, prefix, h, o); err != nil {
+		return
+	}
+
+	// Drop any record padding of the old archive left behind the new
+	// end-of-archive marker.
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	if err = f.Truncate(offset); err != nil {
+		return
+	}
+
+	return f.Close()
+}
+
+// archiveEnd returns the offset of the end-of-archive marker of the tar archive
+// read from f. ErrTruncatedArchive is returned if there is none.
+func archiveEnd(f *os.File) (end int64, err error) {
+	c := &countingReader{r: f}
+	r := tar.NewReader(c)
+	for {
+		if _, err = r.Next(); err == io.EOF {
+			break
+		}
+		if err == nil {
+			_, err = io.Copy(io.Discard, r)
+		}
+		if err == io.ErrUnexpectedEOF {
+			return 0, fmt.Errorf("%s: %w", f.Name(), ErrTruncatedArchive)
+		}
+		if err != nil {
+			return
+		}
+		end = (c.n + blockSize - 1) / blockSize * blockSize
+	}
+
+	marker := make([]byte, 2*blockSize)
+	n, err := f.ReadAt(marker, end)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if n < len(marker) || !bytes.Equal(marker, make([]byte, len(marker))) {
+		return 0, fmt.Errorf("%s: %w", f.Name(), ErrTruncatedArchive)
+	}
+
+	return end, nil
+}
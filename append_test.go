@@ -0,0 +1,81 @@
+package tarski
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAppend(t *testing.T) {
+	first := t.TempDir()
+	if err := os.WriteFile(filepath.Join(first, "a"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second := t.TempDir()
+	if err := os.WriteFile(filepath.Join(second, "b"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	tarball := filepath.Join(dir, archive)
+	if err := Create(tarball, first, first, WithBlockingFactor(20)); err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := AppendSHA256(tarball, second, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"a", "b"}
+	names := readEntryNames(t, tarball)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Found %v instead.", expected, names)
+	}
+
+	if err = Verify(tarball); err != nil {
+		t.Fatal(err)
+	}
+
+	extracted, err := ExtractSHA256(tarball, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, extracted) {
+		t.Fatalf("Expected checksum %x. Received %x instead.", extracted, checksum)
+	}
+
+	empty := filepath.Join(dir, "empty.tar")
+	if err = os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = Append(empty, second, second); err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"b"}
+	names = readEntryNames(t, empty)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Found %v instead.", expected, names)
+	}
+
+	// Cut the archive after the content of b and in the middle of it.
+	for _, size := range []int64{4 * blockSize, 3 * blockSize} {
+		if err = os.Truncate(tarball, size); err != nil {
+			t.Fatal(err)
+		}
+		if err = Append(tarball, second, second); !errors.Is(err, ErrTruncatedArchive) {
+			t.Fatalf("Expected ErrTruncatedArchive. Received %v instead.", err)
+		}
+	}
+
+	compressed := filepath.Join(dir, "test.tar.gz")
+	if err = CreateGzip(compressed, first, first); err != nil {
+		t.Fatal(err)
+	}
+	if err = Append(compressed, second, second); !errors.Is(err, ErrAppendCompressed) {
+		t.Fatalf("Expected ErrAppendCompressed. Received %v instead.", err)
+	}
+}
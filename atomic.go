@@ -0,0 +1,81 @@
+package tarski
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+)
+
+// fsync flushes the file referred to by fd to disk. It is replaced in tests.
+var fsync = unix.Fsync
+
+// ExtractToTempThenMove extracts a tar archive into a temporary directory next
+// to destPath and renames it to destPath once all extracted files and
+// directories have been flushed to disk. Either the complete content of the
+// archive or nothing shows up at destPath even across a power failure. destPath
+// must not exist or be an empty directory. The temporary directory is removed
+// if extraction fails.
+// Flushing every file to disk makes extraction considerably slower than Extract,
+// especially for archives with many small files, so this should be reserved for
+// content that needs to survive crashes such as container image stores.
+func ExtractToTempThenMove(archive string, destPath string, opts ...Option) (err error) {
+	destPath = filepath.Clean(destPath)
+	parent := filepath.Dir(destPath)
+
+	tmp, err := os.MkdirTemp(parent, filepath.Base(destPath)+".new-")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(tmp)
+		}
+	}()
+
+	// MkdirTemp creates the directory with mode 0700.
+	if err = os.Chmod(tmp, 0755); err != nil {
+		return
+	}
+
+	o := newOptions(opts)
+	o.sync = true
+	if err = extractArchiveFile(archive, tmp, nil, o); err != nil {
+		return
+	}
+
+	if err = syncDirs(tmp); err != nil {
+		return
+	}
+
+	if err = os.Rename(tmp, destPath); err != nil {
+		return
+	}
+
+	return syncPath(parent)
+}
+
+// syncDirs flushes path and all directories below it to disk.
+func syncDirs(path string) error {
+	return filepath.Walk(path, func(curpath string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() {
+			return err
+		}
+
+		return syncPath(curpath)
+	})
+}
+
+// syncPath flushes the file or directory at path to disk.
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	if err = fsync(int(f.Fd())); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,65 @@
+package tarski
+
+import (
+	"archive/tar"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractToTempThenMove(t *testing.T) {
+	calls := 0
+	fsync = func(fd int) error {
+		calls++
+		return nil
+	}
+	defer func() {
+		fsync = unix.Fsync
+	}()
+
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/a", Mode: 0644}, "a"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/b", Mode: 0644}, "b"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "c", Mode: 0644}, "c"},
+	})
+
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "dest")
+	if err := ExtractToTempThenMove(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Three files, the temporary and dir directories and the parent.
+	if calls != 6 {
+		t.Fatalf("Expected 6 calls to fsync. Received %d instead.", calls)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dest, "dir", "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "b" {
+		t.Fatalf("Expected %q. Received %q instead.", "b", b)
+	}
+
+	fi, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Fatalf("Expected mode 0755. Received %o instead.", fi.Mode().Perm())
+	}
+
+	if err = ExtractToTempThenMove(tarball, dest); err == nil {
+		t.Fatalf("Expected extraction onto a non-empty directory to fail.")
+	}
+	leftovers, err := filepath.Glob(filepath.Join(parent, "dest.new-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leftovers) != 0 {
+		t.Fatalf("Expected the temporary directory to be removed. Found %v instead.", leftovers)
+	}
+}
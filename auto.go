@@ -0,0 +1,56 @@
+package tarski
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// ExtractAuto extracts a tar archive compressed with any of the supported
+// formats or not compressed at all under path. Unlike Extract it fails with
+// ErrUnknownCompression before touching path if the archive is neither a
+// known compressed format nor a tar archive.
+func ExtractAuto(archive string, path string, opts ...Option) error {
+	if err := checkKnownFormat(archive); err != nil {
+		return err
+	}
+
+	return Extract(archive, path, opts...)
+}
+
+// ExtractAutoSHA256 extracts an archive like ExtractAuto and returns the
+// SHA256-hash checksum of the uncompressed tar stream.
+func ExtractAutoSHA256(archive string, path string, opts ...Option) ([]byte, error) {
+	if err := checkKnownFormat(archive); err != nil {
+		return nil, err
+	}
+
+	return ExtractSHA256(archive, path, opts...)
+}
+
+// checkKnownFormat verifies that archive starts with the magic bytes of a
+// supported compression format, a valid tar header or the end of archive
+// marker.
+func checkKnownFormat(archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b := make([]byte, blockSize)
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	b = b[:n]
+
+	if detectCompression(b) != CompressionNone || detectTarFormat(b) != TarFormatUnknown {
+		return nil
+	}
+	if n == blockSize && bytes.Equal(b, make([]byte, blockSize)) {
+		return nil
+	}
+
+	return ErrUnknownCompression
+}
@@ -0,0 +1,70 @@
+package tarski
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractAuto(t *testing.T) {
+	uncompressed := filepath.Join(t.TempDir(), archive)
+	expected, err := CreateSHA256(uncompressed, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for name, create := range map[string]func(string, string, string, ...Option) error{
+		archive:          Create,
+		archive + ".gz":  CreateGzip,
+		archive + ".bz2": CreateBzip2,
+		archive + ".zst": CreateZstd,
+		archive + ".xz":  CreateXZ,
+	} {
+		tarball := filepath.Join(dir, name)
+		if err = create(tarball, prefix, prefix); err != nil {
+			t.Fatal(err)
+		}
+
+		dest := t.TempDir()
+		if err = ExtractAuto(tarball, dest); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err = os.Stat(filepath.Join(dest, entries[6])); err != nil {
+			t.Fatal(err)
+		}
+
+		checksum, err := ExtractAutoSHA256(tarball, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(checksum, expected) {
+			t.Fatalf("%s: Expected checksum %x. Received %x instead.", name, expected, checksum)
+		}
+	}
+
+	empty := filepath.Join(dir, "empty.tar")
+	if err = os.WriteFile(empty, make([]byte, 2*blockSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ExtractAuto(empty, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	garbage := filepath.Join(dir, "garbage")
+	if err = os.WriteFile(garbage, bytes.Repeat([]byte("garbage"), 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(t.TempDir(), "dest")
+	if err = ExtractAuto(garbage, dest, WithCreateDestination(0755)); !errors.Is(err, ErrUnknownCompression) {
+		t.Fatalf("Expected ErrUnknownCompression. Received %v instead.", err)
+	}
+	if _, err = os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("Expected the destination not to be created. Received %v instead.", err)
+	}
+	if _, err = ExtractAutoSHA256(garbage, t.TempDir()); !errors.Is(err, ErrUnknownCompression) {
+		t.Fatalf("Expected ErrUnknownCompression. Received %v instead.", err)
+	}
+}
@@ -0,0 +1,55 @@
+package tarski
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"time"
+)
+
+// paxCrtime is the PAX record used by GNU tar and star for the birth time.
+const paxCrtime = "SCHILY.crtime"
+
+// GetBirthTime retrieves the birth time of a file, directory or symbolic link
+// using statx(). The boolean reports whether the filesystem provides a birth
+// time. Symbolic links are not followed.
+func GetBirthTime(path string) (time.Time, bool, error) {
+	return birthTime(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// birthTime retrieves the birth time of path relative to dirFd using statx()
+// with flags.
+func birthTime(dirFd int, path string, flags int) (time.Time, bool, error) {
+	var stat unix.Statx_t
+
+	err := unix.Statx(dirFd, path, flags, unix.STATX_BTIME, &stat)
+	if errors.Is(err, unix.ENOSYS) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	if stat.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false, nil
+	}
+
+	return time.Unix(stat.Btime.Sec, int64(stat.Btime.Nsec)), true, nil
+}
+
+// storeBirthTime records the birth time of path relative to dirFd in h if the
+// filesystem provides one.
+func storeBirthTime(h *tar.Header, dirFd int, path string, flags int) error {
+	btime, ok, err := birthTime(dirFd, path, flags)
+	if err != nil || !ok {
+		return err
+	}
+
+	if h.PAXRecords == nil {
+		h.PAXRecords = make(map[string]string)
+	}
+	h.PAXRecords[paxCrtime] = fmt.Sprintf("%d.%09d", btime.Unix(), btime.Nanosecond())
+
+	return nil
+}
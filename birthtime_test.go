@@ -0,0 +1,45 @@
+package tarski
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreBirthTime(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	btime, ok, err := GetBirthTime(filepath.Join(src, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Skip("Filesystem does not report birth times.")
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err = Create(tarball, src, src, WithStoreBirthTime()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	h, err := tar.NewReader(f).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprintf("%d.%09d", btime.Unix(), btime.Nanosecond())
+	if h.PAXRecords[paxCrtime] != expected {
+		t.Fatalf("Expected %s record %s. Received %q instead.", paxCrtime, expected, h.PAXRecords[paxCrtime])
+	}
+}
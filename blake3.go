@@ -0,0 +1,18 @@
+package tarski
+
+import "github.com/zeebo/blake3"
+
+// CreateBLAKE3 creates a tar archive and returns its BLAKE3-hash checksum.
+// Like CreateSHA256 the hash is computed over the tar stream and not over the
+// archived files individually.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateBLAKE3(archive string, path string, prefix string, opts ...Option) ([]byte, error) {
+	return CreateWithHash(archive, path, prefix, blake3.New(), opts...)
+}
+
+// ExtractBLAKE3 extracts a tar archive under path and returns the BLAKE3-hash
+// checksum of the uncompressed tar stream.
+func ExtractBLAKE3(archive string, path string, opts ...Option) ([]byte, error) {
+	return ExtractWithHash(archive, path, blake3.New(), opts...)
+}
@@ -0,0 +1,27 @@
+package tarski
+
+// CreateBzip2 creates a bzip2 compressed tar archive.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateBzip2(archive string, path string, prefix string, opts ...Option) error {
+	return Create(archive, path, prefix, withCompression(CompressionBzip2, opts)...)
+}
+
+// CreateBzip2SHA256 creates a bzip2 compressed tar archive and returns the
+// SHA256-hash checksum of the uncompressed tar stream.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateBzip2SHA256(archive string, path string, prefix string, opts ...Option) ([]byte, error) {
+	return CreateSHA256(archive, path, prefix, withCompression(CompressionBzip2, opts)...)
+}
+
+// ExtractBzip2 extracts a bzip2 compressed tar archive under path.
+func ExtractBzip2(archive string, path string, opts ...Option) error {
+	return Extract(archive, path, withCompression(CompressionBzip2, opts)...)
+}
+
+// ExtractBzip2SHA256 extracts a bzip2 compressed tar archive under path and
+// returns the SHA256-hash checksum of the uncompressed tar stream.
+func ExtractBzip2SHA256(archive string, path string, opts ...Option) ([]byte, error) {
+	return ExtractSHA256(archive, path, withCompression(CompressionBzip2, opts)...)
+}
@@ -0,0 +1,71 @@
+package tarski
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBzip2(t *testing.T) {
+	uncompressed := filepath.Join(t.TempDir(), archive)
+	expected, err := CreateSHA256(uncompressed, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive+".bz2")
+	if err = CreateBzip2(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := IsBzip2(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Expected a bzip2 compressed archive.")
+	}
+
+	dest := t.TempDir()
+	if err = ExtractBzip2(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dest, entries[6])); err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := CreateBzip2SHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected the checksum %x of the uncompressed stream. Received %x instead.", expected, checksum)
+	}
+
+	checksum, err = ExtractBzip2SHA256(tarball, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected the checksum %x of the uncompressed stream. Received %x instead.", expected, checksum)
+	}
+
+	if err = ExtractBzip2(uncompressed, t.TempDir()); err == nil {
+		t.Fatal("Expected extracting an uncompressed archive with ExtractBzip2 to fail.")
+	}
+}
+
+func TestExtractDetectsBzip2(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive+".bz2")
+	if err := CreateBzip2(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	if err := Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, entries[6])); err != nil {
+		t.Fatal(err)
+	}
+}
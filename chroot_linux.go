@@ -0,0 +1,61 @@
+//go:build linux
+
+package tarski
+
+import (
+	"archive/tar"
+	"golang.org/x/sys/unix"
+	"io"
+	"os"
+	"runtime"
+)
+
+// ExtractWithChroot extracts a tar archive into rootPath after confining the
+// extraction to rootPath with chroot(2). Entries escaping through ".." and
+// symbolic links, including absolute ones, resolve inside rootPath so nothing
+// outside of it can be written.
+//
+// Go processes are multi-threaded which rules out unsharing a user namespace.
+// Instead the filesystem context of a dedicated thread is unshared and only
+// that thread is chrooted. The thread is discarded once extraction has
+// finished. This requires CAP_SYS_CHROOT.
+func ExtractWithChroot(archive string, rootPath string, opts ...Option) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err = os.MkdirAll(rootPath, 0755); err != nil {
+		return err
+	}
+
+	c := make(chan error, 1)
+	go func() {
+		// The thread is intentionally never unlocked so it is terminated
+		// when this goroutine exits instead of being reused with a
+		// different root.
+		runtime.LockOSThread()
+
+		if err := unix.Unshare(unix.CLONE_FS); err != nil {
+			c <- err
+			return
+		}
+		if err := unix.Chroot(rootPath); err != nil {
+			c <- err
+			return
+		}
+		if err := unix.Chdir("/"); err != nil {
+			c <- err
+			return
+		}
+
+		err := doExtract(tar.NewReader(f), "/", newOptions(opts))
+		if err == io.EOF {
+			err = nil
+		}
+		c <- err
+	}()
+
+	return <-c
+}
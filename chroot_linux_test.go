@@ -0,0 +1,46 @@
+//go:build linux
+
+package tarski
+
+import (
+	"archive/tar"
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractWithChroot(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Chrooting requires root.")
+	}
+
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "../../escape", Mode: 0644}, "escape"},
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "abs", Linkname: "/"}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "abs/through-link", Mode: 0644}, "link"},
+	})
+
+	parent := t.TempDir()
+	root := filepath.Join(parent, "a", "root")
+	err := ExtractWithChroot(tarball, root)
+	if errors.Is(err, unix.EPERM) {
+		t.Skip("Chrooting is not permitted.")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"escape", "through-link"} {
+		if _, err = os.Stat(filepath.Join(root, name)); err != nil {
+			t.Fatalf("Expected %s to be confined to the root: %v", name, err)
+		}
+	}
+
+	for _, name := range []string{filepath.Join(parent, "escape"), "/through-link"} {
+		if _, err = os.Lstat(name); !os.IsNotExist(err) {
+			t.Fatalf("Expected %s to not exist outside of the root.", name)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package tarski
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+)
+
+// CreateWithComment creates a tar archive whose first entry is a GNU tar volume
+// label holding comment. This can be used to embed e.g. build IDs or provenance
+// information in an archive. Such archives need to be extracted with
+// WithSkipVolumeHeaders().
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateWithComment(archive string, path string, prefix string, comment string, opts ...Option) error {
+	o := newOptions(opts)
+	return createArchiveWith(archive, nil, o, func(w *tar.Writer) error {
+		err := w.WriteHeader(&tar.Header{
+			Typeflag: typeGNUVolHeader,
+			Name:     comment,
+			Format:   tar.FormatGNU,
+		})
+		if err != nil {
+			return err
+		}
+
+		return doCreate(w, path, prefix, o)
+	})
+}
+
+// ReadComment returns the comment stored in the GNU tar volume label of an
+// archive. The empty string is returned if the first entry of the archive is
+// not a volume label. Compressed archives are decompressed transparently.
+func ReadComment(archive string) (string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	c, _, err := decompressReader(f, &Options{})
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	h, err := tar.NewReader(c).Next()
+	if err == io.EOF {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if h.Typeflag != typeGNUVolHeader {
+		return "", nil
+	}
+
+	return h.Name, nil
+}
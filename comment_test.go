@@ -0,0 +1,70 @@
+package tarski
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateWithComment(t *testing.T) {
+	comment := "build 1234 2017-05-01T12:00:00Z"
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := CreateWithComment(tarball, prefix, prefix, comment); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := ReadComment(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != comment {
+		t.Fatalf("Expected comment %q. Received %q instead.", comment, found)
+	}
+
+	dest := t.TempDir()
+	if err = Extract(tarball, dest, WithSkipVolumeHeaders()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dest, entries[1])); err != nil {
+		t.Fatal(err)
+	}
+
+	plain := filepath.Join(t.TempDir(), archive)
+	if err = Create(plain, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err = ReadComment(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != "" {
+		t.Fatalf("Expected no comment. Received %q instead.", found)
+	}
+}
+
+func TestCreateWithCommentCompressed(t *testing.T) {
+	comment := "build 1234"
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := CreateWithComment(tarball, prefix, prefix, comment, WithGzip()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := detectCompression(data); c != CompressionGzip {
+		t.Fatalf("Expected a gzip compressed archive. Received %s instead.", c)
+	}
+
+	found, err := ReadComment(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found != comment {
+		t.Fatalf("Expected comment %q. Received %q instead.", comment, found)
+	}
+}
@@ -0,0 +1,133 @@
+package tarski
+
+import (
+	"bufio"
+	stdbzip2 "compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"io"
+)
+
+// nopWriteCloser adds a no-op Close method to an io.Writer.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// ZstdOptions tunes the zstd encoder used for archive creation.
+type ZstdOptions struct {
+	// Level is the zstd compression level from 1 to 22. Zero selects the
+	// default level. Levels are mapped to the closest level supported by
+	// the encoder.
+	Level int
+
+	// Threads is the number of goroutines used for encoding. Zero uses
+	// GOMAXPROCS.
+	Threads int
+}
+
+func (z ZstdOptions) encoderOptions() (opts []zstd.EOption) {
+	if z.Level != 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(z.Level)))
+	}
+	if z.Threads > 0 {
+		opts = append(opts, zstd.WithEncoderConcurrency(z.Threads))
+	}
+
+	return
+}
+
+// XZOptions tunes the xz encoder used for archive creation.
+type XZOptions struct {
+	// Level is the xz preset level from 1 to 9. It selects the dictionary
+	// size of the corresponding xz preset. Zero selects the default level 6
+	// and higher levels compress better but need more memory. Other values
+	// make archive creation fail with ErrInvalidXZLevel.
+	Level int
+}
+
+// xzDictCaps holds the dictionary sizes of the xz presets 0 to 9.
+var xzDictCaps = [...]int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+func (x XZOptions) writerConfig() (xz.WriterConfig, error) {
+	level := x.Level
+	if level == 0 {
+		level = 6
+	}
+	if level < 1 || level >= len(xzDictCaps) {
+		return xz.WriterConfig{}, fmt.Errorf("%d: %w", x.Level, ErrInvalidXZLevel)
+	}
+
+	return xz.WriterConfig{DictCap: xzDictCaps[level]}, nil
+}
+
+// withCompression appends an Option selecting compression c to opts.
+func withCompression(c CompressionFormat, opts []Option) []Option {
+	return append(opts[:len(opts):len(opts)], func(o *Options) {
+		o.Compression = c
+	})
+}
+
+// compressWriter wraps w in a writer compressing with the format selected in
+// o. Closing the returned writer flushes the compressor but does not close w.
+func compressWriter(w io.Writer, o *Options) (io.WriteCloser, error) {
+	switch o.Compression {
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionBzip2:
+		return bzip2.NewWriter(w, &bzip2.WriterConfig{Level: bzip2.DefaultCompression})
+	case CompressionZstd:
+		return zstd.NewWriter(w, o.Zstd.encoderOptions()...)
+	case CompressionXZ:
+		c, err := o.XZ.writerConfig()
+		if err != nil {
+			return nil, err
+		}
+		return c.NewWriter(w)
+	}
+
+	return nil, fmt.Errorf("Writing %s compressed archives is not supported.", o.Compression)
+}
+
+// decompressReader detects the compression of r from its magic bytes and
+// returns a reader of the decompressed stream. If o selects a compression
+// format the archive has to use it.
+func decompressReader(r io.Reader, o *Options) (io.ReadCloser, CompressionFormat, error) {
+	b := bufio.NewReader(r)
+	magic, _ := b.Peek(blockSize)
+
+	c := detectCompression(magic)
+	if o.Compression != CompressionNone && c != o.Compression {
+		return nil, c, fmt.Errorf("Archive is not %s compressed.", o.Compression)
+	}
+
+	switch c {
+	case CompressionNone:
+		return io.NopCloser(b), c, nil
+	case CompressionGzip:
+		z, err := gzip.NewReader(b)
+		return z, c, err
+	case CompressionBzip2:
+		return io.NopCloser(stdbzip2.NewReader(b)), c, nil
+	case CompressionZstd:
+		z, err := zstd.NewReader(b)
+		if err != nil {
+			return nil, c, err
+		}
+		return z.IOReadCloser(), c, nil
+	case CompressionXZ:
+		z, err := xz.NewReader(b)
+		if err != nil {
+			return nil, c, err
+		}
+		return io.NopCloser(z), c, nil
+	}
+
+	return nil, c, fmt.Errorf("Reading %s compressed archives is not supported.", c)
+}
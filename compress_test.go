@@ -0,0 +1,59 @@
+package tarski
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateExtractBzip2(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive+".bz2")
+	checksum, err := CreateSHA256(tarball, prefix, prefix, WithBzip2())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := IsBzip2(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Expected a bzip2 compressed archive.")
+	}
+
+	uncompressed := filepath.Join(t.TempDir(), archive)
+	expected, err := CreateSHA256(uncompressed, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected the checksum %x of the uncompressed stream. Received %x instead.", expected, checksum)
+	}
+
+	// The compression is detected without passing WithBzip2.
+	dest := t.TempDir()
+	checksum, err = ExtractSHA256(tarball, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected the checksum %x of the uncompressed stream. Received %x instead.", expected, checksum)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, entries[6]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := os.ReadFile(prefix + entries[6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, orig) {
+		t.Fatalf("Expected content %q. Received %q instead.", orig, data)
+	}
+
+	if err = Extract(uncompressed, t.TempDir(), WithBzip2()); err == nil {
+		t.Fatal("Expected extracting an uncompressed archive with WithBzip2 to fail.")
+	}
+}
@@ -0,0 +1,37 @@
+package tarski
+
+import (
+	"archive/tar"
+	"io"
+)
+
+// Concatenate streams the entries of all archives into dst as a single tar
+// archive. Entries are copied in order without removing duplicates so an
+// entry found in a later archive takes precedence on extraction. The
+// result ends with a single end-of-archive marker.
+func Concatenate(dst io.Writer, archives ...io.Reader) error {
+	w := tar.NewWriter(dst)
+
+	for _, a := range archives {
+		r := tar.NewReader(a)
+		for {
+			h, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			if err = w.WriteHeader(h); err != nil {
+				return err
+			}
+
+			if _, err = io.Copy(w, r); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.Close()
+}
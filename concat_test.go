@@ -0,0 +1,50 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConcatenate(t *testing.T) {
+	var archives []*os.File
+	for _, name := range []string{"one", "two", "three"} {
+		tarball := writeTestArchive(t, []testEntry{
+			{&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644}, name},
+		})
+		f, err := os.Open(tarball)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		archives = append(archives, f)
+	}
+
+	var buf bytes.Buffer
+	if err := Concatenate(&buf, archives[0], archives[1], archives[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := os.WriteFile(tarball, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	names := readEntryNames(t, tarball)
+	expected := []string{"one", "two", "three"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Received %v instead.", expected, names)
+	}
+
+	// Two blocks per entry followed by a single end-of-archive marker of
+	// two blocks.
+	if buf.Len() != 4*2*blockSize {
+		t.Fatalf("Expected an archive of %d bytes. Received %d bytes instead.", 4*2*blockSize, buf.Len())
+	}
+	if !bytes.Equal(buf.Bytes()[3*2*blockSize:], make([]byte, 2*blockSize)) {
+		t.Fatal("Expected the end-of-archive marker after the last entry.")
+	}
+}
@@ -0,0 +1,32 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// ConcurrentExtract extracts the archive held in archiveBytes under each of
+// destinations in parallel. It returns one error per destination which is nil
+// if extraction to that destination succeeded. A ProgressFunc passed via
+// WithProgress is called from multiple goroutines.
+func ConcurrentExtract(archiveBytes []byte, destinations []string, opts ...Option) []error {
+	errs := make([]error, len(destinations))
+
+	var wg sync.WaitGroup
+	for i, dest := range destinations {
+		wg.Add(1)
+		go func(i int, dest string) {
+			defer wg.Done()
+
+			r := tar.NewReader(bytes.NewReader(archiveBytes))
+			if err := doExtract(r, dest, newOptions(opts)); err != io.EOF {
+				errs[i] = err
+			}
+		}(i, dest)
+	}
+	wg.Wait()
+
+	return errs
+}
@@ -0,0 +1,92 @@
+package tarski
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConcurrentExtract(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var destinations []string
+	for i := 0; i < 4; i++ {
+		destinations = append(destinations, t.TempDir())
+	}
+	// The parent of a destination is a file so extraction to it fails.
+	file := filepath.Join(t.TempDir(), "file")
+	if err = os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	destinations = append(destinations, filepath.Join(file, "dest"))
+
+	errs := ConcurrentExtract(data, destinations)
+	if len(errs) != len(destinations) {
+		t.Fatalf("Expected %d errors. Received %d instead.", len(destinations), len(errs))
+	}
+	for i, err := range errs[:len(errs)-1] {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = os.Stat(filepath.Join(destinations[i], entries[6])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if errs[len(errs)-1] == nil {
+		t.Fatal("Expected extraction below a regular file to fail.")
+	}
+}
+
+func benchmarkArchive(b *testing.B) []byte {
+	tarball := filepath.Join(b.TempDir(), archive)
+	if err := Create(tarball, prefix, prefix); err != nil {
+		b.Fatal(err)
+	}
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return data
+}
+
+func BenchmarkConcurrentExtract(b *testing.B) {
+	data := benchmarkArchive(b)
+
+	for i := 0; i < b.N; i++ {
+		root := b.TempDir()
+		var destinations []string
+		for j := 0; j < 10; j++ {
+			destinations = append(destinations, filepath.Join(root, string(rune('a'+j))))
+		}
+
+		for _, err := range ConcurrentExtract(data, destinations) {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkSequentialExtract(b *testing.B) {
+	data := benchmarkArchive(b)
+
+	for i := 0; i < b.N; i++ {
+		root := b.TempDir()
+		for j := 0; j < 10; j++ {
+			dest := []string{filepath.Join(root, string(rune('a'+j)))}
+			for _, err := range ConcurrentExtract(data, dest) {
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package tarski
+
+import (
+	"context"
+	"crypto/sha256"
+)
+
+// CreateContext creates a tar archive like Create but stops with ctx.Err() once
+// ctx is done. The archive is left incomplete in that case.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateContext(ctx context.Context, archive string, path string, prefix string, opts ...Option) error {
+	return createArchive(archive, path, prefix, nil, contextOptions(ctx, opts))
+}
+
+// CreateSHA256Context creates a tar archive like CreateSHA256 but stops with
+// ctx.Err() once ctx is done.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateSHA256Context(ctx context.Context, archive string, path string, prefix string, opts ...Option) (checksum []byte, err error) {
+	b := sha256.New()
+	if err = createArchive(archive, path, prefix, b, contextOptions(ctx, opts)); err != nil {
+		return
+	}
+
+	return b.Sum(nil), nil
+}
+
+// ExtractContext extracts a tar archive like Extract but stops with ctx.Err()
+// once ctx is done. Entries extracted up to then are kept.
+func ExtractContext(ctx context.Context, archive string, path string, opts ...Option) error {
+	return extractArchiveFile(archive, path, nil, contextOptions(ctx, opts))
+}
+
+// ExtractSHA256Context extracts a tar archive like ExtractSHA256 but stops with
+// ctx.Err() once ctx is done.
+func ExtractSHA256Context(ctx context.Context, archive string, path string, opts ...Option) (checksum []byte, err error) {
+	b := sha256.New()
+	if err = extractArchiveFile(archive, path, b, contextOptions(ctx, opts)); err != nil {
+		return
+	}
+
+	return b.Sum(nil), nil
+}
+
+func contextOptions(ctx context.Context, opts []Option) *Options {
+	o := newOptions(opts)
+	o.ctx = ctx
+
+	return o
+}
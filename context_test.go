@@ -0,0 +1,61 @@
+package tarski
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateContext(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := CreateContext(context.Background(), tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := CreateContext(ctx, filepath.Join(t.TempDir(), archive), prefix, prefix); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled. Received %v instead.", err)
+	}
+	if _, err := CreateSHA256Context(ctx, filepath.Join(t.TempDir(), archive), prefix, prefix); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled. Received %v instead.", err)
+	}
+
+	// Cancelling halfway through stops the walk.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	seen := 0
+	err := CreateContext(ctx, filepath.Join(t.TempDir(), archive), prefix, prefix, WithXattrProgress(func(string, int) {
+		seen++
+		cancel()
+	}))
+	if !errors.Is(err, context.Canceled) || seen != 1 {
+		t.Fatalf("Expected creation to stop after one entry. Received %v after %d entries instead.", err, seen)
+	}
+}
+
+func TestExtractContext(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	expected, err := CreateSHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := ExtractSHA256Context(context.Background(), tarball, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(checksum) != string(expected) {
+		t.Fatalf("Expected checksum %x. Received %x instead.", expected, checksum)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = ExtractContext(ctx, tarball, t.TempDir()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled. Received %v instead.", err)
+	}
+	if _, err = ExtractSHA256Context(ctx, tarball, t.TempDir()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled. Received %v instead.", err)
+	}
+}
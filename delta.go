@@ -0,0 +1,205 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// CreateDelta creates a tar archive holding only the entries of newPath that
+// differ from their counterpart under oldPath. Regular files of different size
+// are changed and files of the same size and modification time unchanged; all
+// other regular files are compared by their SHA256-hash. Symbolic links are
+// compared by their target and all other entries by their type. Entries
+// present under oldPath but missing under newPath are written as whiteout
+// entries. The names of all written entries are returned. Entries are selected
+// and named like Create does, so WithExcludeGlob, WithExcludeHidden,
+// WithFollowSymlinks and WithPathTransform apply; entries excluded from newPath
+// are never removed. The archive is written as configured by opts, e.g.
+// compressed with WithGzip().
+// The string given by prefix will be stripped from all entries found under
+// newPath.
+func CreateDelta(archive string, newPath string, oldPath string, prefix string, opts ...Option) (changed []string, err error) {
+	o := newOptions(opts)
+	err = createArchiveWith(archive, nil, o, func(w *tar.Writer) error {
+		var err error
+		changed, err = writeDelta(w, newPath, oldPath, prefix, o)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changed, nil
+}
+
+// writeDelta writes the entries of the delta between newPath and oldPath to w
+// and returns their names.
+func writeDelta(w *tar.Writer, newPath string, oldPath string, prefix string, o *Options) (changed []string, err error) {
+	if err = writeGlobalHeader(w, o); err != nil {
+		return
+	}
+
+	err = walk(newPath, prefix, o, func(walked string, curpath string, s string, fi os.FileInfo) error {
+		rel, err := filepath.Rel(newPath, walked)
+		if err != nil {
+			return err
+		}
+
+		same, err := sameContent(curpath, fi, filepath.Join(oldPath, rel))
+		if err != nil || same {
+			return err
+		}
+
+		if err = writeEntry(w, curpath, s, fi, o, nil); err != nil {
+			return err
+		}
+		changed = append(changed, s)
+
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	err = filepath.Walk(oldPath, func(curpath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(oldPath, curpath)
+		if err != nil {
+			return err
+		}
+
+		newpath := filepath.Join(newPath, rel)
+		if _, err = os.Lstat(newpath); !os.IsNotExist(err) {
+			return err
+		}
+
+		// Excluded entries are not part of the delta and therefore not
+		// removed either.
+		s := CleanEntryName(fi, newpath, prefix)
+		excluded, err := o.excluded(s)
+		if o.ExcludeHidden && filepath.Base(curpath)[0] == '.' {
+			excluded = true
+		}
+		if err != nil || excluded {
+			if err == nil && fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if o.PathTransform != nil {
+			if s, err = transformEntryName(s, fi, o); err != nil || s == "" {
+				return err
+			}
+		}
+
+		s = whiteoutName(s)
+		err = w.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     s,
+			Mode:     0644,
+			Format:   o.Format,
+		})
+		if err != nil {
+			return err
+		}
+		changed = append(changed, s)
+
+		// A whiteout for a directory covers all of its entries.
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// whiteoutName returns the name of the whiteout entry removing entry.
+func whiteoutName(entry string) string {
+	dir, base := path.Split(strings.TrimSuffix(entry, "/"))
+	return dir + whiteoutPrefix + base
+}
+
+// sameContent reports whether oldpath has the same content as newpath.
+func sameContent(newpath string, fi os.FileInfo, oldpath string) (bool, error) {
+	ofi, err := os.Lstat(oldpath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if fi.Mode().Type() != ofi.Mode().Type() {
+		return false, nil
+	}
+
+	switch {
+	case fi.Mode()&os.ModeSymlink == os.ModeSymlink:
+		a, err := os.Readlink(newpath)
+		if err != nil {
+			return false, err
+		}
+		b, err := os.Readlink(oldpath)
+		if err != nil {
+			return false, err
+		}
+		return a == b, nil
+	case fi.Mode().IsRegular():
+		if fi.Size() != ofi.Size() {
+			return false, nil
+		}
+		// Only files whose modification time changed without changing
+		// their size need to be hashed.
+		if fi.ModTime().Equal(ofi.ModTime()) {
+			return true, nil
+		}
+		a, err := hashFile(newpath)
+		if err != nil {
+			return false, err
+		}
+		b, err := hashFile(oldpath)
+		if err != nil {
+			return false, err
+		}
+		return bytes.Equal(a, b), nil
+	}
+
+	return true, nil
+}
+
+// hashFile computes the SHA256-hash of the content of a file.
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// ApplyDelta applies a delta archive created by CreateDelta to the tree at
+// baseDir. Whiteout entries remove the corresponding files, added and modified
+// entries replace their counterparts and all other files are left alone.
+// Entries and whiteouts escaping baseDir, also through symbolic links, are
+// rejected with ErrUnsafePath just like for ExtractArchiveLayer.
+func ApplyDelta(baseDir string, deltaArchive string) error {
+	return ExtractArchiveLayer(deltaArchive, baseDir)
+}
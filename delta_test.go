@@ -0,0 +1,281 @@
+package tarski
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCreateDelta(t *testing.T) {
+	oldPath := t.TempDir()
+	newPath := t.TempDir()
+
+	files := map[string]map[string]string{
+		oldPath: {"same": "same", "modified": "old", "deleted": "deleted", "gone/file": "gone"},
+		newPath: {"same": "same", "modified": "new", "added": "added"},
+	}
+	for root, content := range files {
+		for name, data := range content {
+			p := filepath.Join(root, name)
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Files of the same size are only hashed if their modification time
+	// differs. Make sure it does as both trees are written within the same
+	// timestamp granularity.
+	mtime := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(newPath, "modified"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	changed, err := CreateDelta(tarball, newPath, oldPath, newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{".wh.deleted", ".wh.gone", "added", "modified"}
+	sort.Strings(changed)
+	if !reflect.DeepEqual(changed, expected) {
+		t.Fatalf("Expected changed entries %v. Received %v instead.", expected, changed)
+	}
+
+	names := readEntryNames(t, tarball)
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected archive entries %v. Found %v instead.", expected, names)
+	}
+}
+
+// treeContents maps the names of all files and directories below root to their
+// content.
+func treeContents(t *testing.T, root string) map[string]string {
+	tree := make(map[string]string)
+	err := filepath.Walk(root, func(curpath string, fi os.FileInfo, err error) error {
+		if err != nil || curpath == root {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, curpath)
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			tree[rel+"/"] = ""
+			return nil
+		}
+
+		b, err := os.ReadFile(curpath)
+		tree[rel] = string(b)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tree
+}
+
+func TestApplyDelta(t *testing.T) {
+	src := t.TempDir()
+	for name, data := range map[string]string{"same": "same", "modified": "old", "deleted": "deleted", "gone/file": "gone", "kept/file": "kept"} {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := filepath.Join(t.TempDir(), "first.tar")
+	if err := Create(first, src, src); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(src, "modified"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "kept", "added"), []byte("added"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(src, "deleted")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(src, "gone")); err != nil {
+		t.Fatal(err)
+	}
+
+	second := filepath.Join(t.TempDir(), "second.tar")
+	if err := Create(second, src, src); err != nil {
+		t.Fatal(err)
+	}
+
+	old := t.TempDir()
+	if err := Extract(first, old); err != nil {
+		t.Fatal(err)
+	}
+	delta := filepath.Join(t.TempDir(), "delta.tar")
+	if _, err := CreateDelta(delta, src, old, src); err != nil {
+		t.Fatal(err)
+	}
+
+	base := t.TempDir()
+	if err := Extract(first, base); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyDelta(base, delta); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := t.TempDir()
+	if err := Extract(second, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	if found, want := treeContents(t, base), treeContents(t, expected); !reflect.DeepEqual(found, want) {
+		t.Fatalf("Expected %v after applying the delta. Found %v instead.", want, found)
+	}
+}
+
+func TestApplyDeltaUnsafe(t *testing.T) {
+	parent := t.TempDir()
+	base := filepath.Join(parent, "base")
+	if err := os.Mkdir(base, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "file"), []byte("file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	whiteout := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeReg, Name: ".wh..", Mode: 0644}, ""},
+	})
+	if err := ApplyDelta(base, whiteout); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("Expected ErrUnsafePath. Received %v instead.", err)
+	}
+
+	// The link is rewritten to point to the base directory itself.
+	escape := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "evil", Linkname: "../"}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "evil/pwned", Mode: 0644}, "pwned"},
+	})
+	if err := ApplyDelta(base, escape); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "pwned")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(base, "file")); err != nil {
+		t.Fatalf("Expected the base directory to be left alone: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(parent, "pwned")); !os.IsNotExist(err) {
+		t.Fatalf("Expected nothing to be written outside of the base directory.")
+	}
+}
+
+func TestCreateDeltaOptions(t *testing.T) {
+	oldPath := t.TempDir()
+	newPath := t.TempDir()
+	mtime := time.Unix(1700000000, 0)
+	for root, content := range map[string]string{oldPath: "old", newPath: "new"} {
+		for _, name := range []string{"touched", "untouched"} {
+			p := filepath.Join(root, name)
+			if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(p, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := os.Chtimes(filepath.Join(newPath, "touched"), mtime, mtime.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	changed, err := CreateDelta(tarball, newPath, oldPath, newPath, WithGzip())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The untouched file has the same size and modification time and is
+	// not hashed.
+	expected := []string{"touched"}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Fatalf("Expected changed entries %v. Received %v instead.", expected, changed)
+	}
+
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := detectCompression(data); c != CompressionGzip {
+		t.Fatalf("Expected a gzip compressed archive. Received %s instead.", c)
+	}
+
+	if err = ApplyDelta(oldPath, tarball); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(filepath.Join(oldPath, "touched"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new" {
+		t.Fatalf("Expected content %q. Received %q instead.", "new", data)
+	}
+}
+
+func TestCreateDeltaWalkOptions(t *testing.T) {
+	oldPath := t.TempDir()
+	newPath := t.TempDir()
+	external := filepath.Join(t.TempDir(), "external")
+
+	files := map[string]map[string]string{
+		oldPath: {"link": "linked", "removed": "removed", "old.log": "log"},
+		newPath: {"added": "added", "debug.log": "log"},
+	}
+	for root, content := range files {
+		for name, data := range content {
+			if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := os.WriteFile(external, []byte("linked"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(external, filepath.Join(newPath, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	changed, err := CreateDelta(tarball, newPath, oldPath, newPath,
+		WithExcludeGlob("*.log"),
+		WithFollowSymlinks(),
+		WithPathTransform(func(name string) (string, error) { return "layer/" + name, nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The followed link has the same content as the old file and excluded
+	// files are neither archived nor removed.
+	expected := []string{"layer/.wh.removed", "layer/added"}
+	sort.Strings(changed)
+	if !reflect.DeepEqual(changed, expected) {
+		t.Fatalf("Expected changed entries %v. Received %v instead.", expected, changed)
+	}
+}
@@ -0,0 +1,153 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"sort"
+)
+
+// ContentDiff describes how the content of an entry compares between two
+// archives. SizeA or SizeB is -1 if the entry is missing from the respective
+// archive.
+type ContentDiff struct {
+	Name         string
+	SizeA        int64
+	SizeB        int64
+	ContentEqual bool
+}
+
+// DiffContent compares the content of two archives ignoring metadata such as
+// ownership, permissions and timestamps. Regular files of the same size are
+// compared by their SHA256-hash while regular files of different sizes are
+// reported without being hashed. Other entries are compared by type and link
+// target. The comparison of every entry found in either archive is returned,
+// sorted by name.
+func DiffContent(archiveA string, archiveB string) ([]ContentDiff, error) {
+	a, err := readHeaders(archiveA)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := readHeaders(archiveB)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only regular files present in both archives with the same size need
+	// to be hashed.
+	hash := make(map[string]bool)
+	for name, ha := range a {
+		hb, ok := b[name]
+		if ok && ha.Typeflag == tar.TypeReg && hb.Typeflag == tar.TypeReg && ha.Size == hb.Size {
+			hash[name] = true
+		}
+	}
+
+	sumsA, err := hashEntries(archiveA, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	sumsB, err := hashEntries(archiveB, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []ContentDiff
+	for name, ha := range a {
+		hb, ok := b[name]
+		if !ok {
+			diffs = append(diffs, ContentDiff{Name: name, SizeA: ha.Size, SizeB: -1})
+			continue
+		}
+
+		var equal bool
+		if hash[name] {
+			equal = bytes.Equal(sumsA[name], sumsB[name])
+		} else {
+			equal = ha.Typeflag == hb.Typeflag && ha.Typeflag != tar.TypeReg && ha.Linkname == hb.Linkname
+		}
+
+		diffs = append(diffs, ContentDiff{Name: name, SizeA: ha.Size, SizeB: hb.Size, ContentEqual: equal})
+	}
+
+	for name, hb := range b {
+		if _, ok := a[name]; !ok {
+			diffs = append(diffs, ContentDiff{Name: name, SizeA: -1, SizeB: hb.Size})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Name < diffs[j].Name
+	})
+
+	return diffs, nil
+}
+
+// readHeaders reads all headers of an archive keyed by entry name. Later
+// entries replace earlier entries of the same name.
+func readHeaders(archive string) (map[string]*tar.Header, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	headers := make(map[string]*tar.Header)
+
+	r := tar.NewReader(f)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		headers[h.Name] = h
+	}
+
+	return headers, nil
+}
+
+// hashEntries computes the SHA256-hash of the content of the entries named in
+// names.
+func hashEntries(archive string, names map[string]bool) (map[string][]byte, error) {
+	sums := make(map[string][]byte, len(names))
+	if len(names) == 0 {
+		return sums, nil
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := tar.NewReader(f)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if !names[h.Name] {
+			continue
+		}
+
+		s := sha256.New()
+		if _, err = io.Copy(s, r); err != nil {
+			return nil, err
+		}
+		sums[h.Name] = s.Sum(nil)
+	}
+
+	return sums, nil
+}
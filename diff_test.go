@@ -0,0 +1,55 @@
+package tarski
+
+import (
+	"archive/tar"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffContent(t *testing.T) {
+	a := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "same", Mode: 0644}, content: "same"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "removed", Mode: 0644}, content: "removed"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "resized", Mode: 0644}, content: "short"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "modified", Mode: 0644}, content: "aaaa"},
+		{header: &tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "same"}},
+	})
+	b := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0700}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "same", Mode: 0600, ModTime: time.Now()}, content: "same"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "added", Mode: 0644}, content: "added"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "resized", Mode: 0644}, content: "much longer"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "modified", Mode: 0644}, content: "bbbb"},
+		{header: &tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "same"}},
+	})
+
+	diffs, err := DiffContent(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []ContentDiff{
+		{Name: "added", SizeA: -1, SizeB: 5},
+		{Name: "dir/", ContentEqual: true},
+		{Name: "link", ContentEqual: true},
+		{Name: "modified", SizeA: 4, SizeB: 4},
+		{Name: "removed", SizeA: 7, SizeB: -1},
+		{Name: "resized", SizeA: 5, SizeB: 11},
+		{Name: "same", SizeA: 4, SizeB: 4, ContentEqual: true},
+	}
+	if !reflect.DeepEqual(diffs, expected) {
+		t.Fatalf("Expected differences %+v. Received %+v instead.", expected, diffs)
+	}
+
+	diffs, err = DiffContent(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range diffs {
+		if !d.ContentEqual {
+			t.Fatalf("Expected %s to be equal when comparing an archive to itself.", d.Name)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package tarski
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDirectoryMissing is returned by ExtractFilesOnly when the directory a file
+// is supposed to be extracted into does not exist.
+var ErrDirectoryMissing = errors.New("Destination directory does not exist.")
+
+// ErrMultiVolumeArchive is returned when a GNU tar volume header is found
+// during extraction. Multi-volume archives need to be reassembled into a single
+// archive before they can be extracted.
+var ErrMultiVolumeArchive = errors.New("Multi-volume archives are not supported.")
+
+// ErrUnsafePath is returned when an entry would be extracted outside of the
+// destination directory.
+var ErrUnsafePath = errors.New("Entry escapes the destination directory.")
+
+// ErrSignatureMissing is returned by ExtractVerified when the archive does not
+// end with a signature.
+var ErrSignatureMissing = errors.New("Archive is not signed.")
+
+// ErrInvalidSignature is returned by ExtractVerified when the signature of an
+// archive does not verify.
+var ErrInvalidSignature = errors.New("Archive signature is invalid.")
+
+// ErrSymlinkLoop is returned by archive creation with WithFollowSymlinks when
+// symbolic links to directories nest more than maxSymlinkDepth levels deep.
+var ErrSymlinkLoop = errors.New("Too many levels of symbolic links.")
+
+// ErrXattrNotUTF8 is returned when an extended attribute value that is not
+// valid UTF-8 is archived with XattrEncodingRaw.
+var ErrXattrNotUTF8 = errors.New("Extended attribute value is not valid UTF-8.")
+
+// ErrXattrTooLarge is returned when an extended attribute value exceeds the
+// limit set with WithXattrSizeLimit.
+var ErrXattrTooLarge = errors.New("Extended attribute value exceeds the size limit.")
+
+// ErrInvalidRecordSize is returned when the record size set with WithRecordSize
+// is not a positive multiple of 512 bytes.
+var ErrInvalidRecordSize = errors.New("Record size must be a multiple of 512 bytes.")
+
+// ErrInvalidXZLevel is returned when the level set with WithXZOptions is not
+// between 1 and 9.
+var ErrInvalidXZLevel = errors.New("XZ level must be between 1 and 9.")
+
+// ErrInvalidTarPath is returned when an entry name cannot be stored in an
+// archive.
+var ErrInvalidTarPath = errors.New("Invalid entry name.")
+
+// ErrTruncatedArchive is returned by Append when an archive does not end with
+// an end-of-archive marker of two zero blocks.
+var ErrTruncatedArchive = errors.New("Archive does not end with an end-of-archive marker.")
+
+// ErrAppendCompressed is returned by Append when the archive is compressed.
+var ErrAppendCompressed = errors.New("Cannot append to compressed archives.")
+
+// ErrUnknownCompression is returned by ExtractAuto when an archive is neither
+// compressed with a supported format nor a tar archive.
+var ErrUnknownCompression = errors.New("Unknown compression format.")
+
+// ErrAbsoluteSymlink is returned when a symbolic link pointing to an absolute
+// path is extracted with AbsoluteSymlinkError.
+var ErrAbsoluteSymlink = errors.New("Symbolic link points to an absolute path.")
+
+// ErrUnsafeSymlink is returned when a symbolic link pointing outside of the
+// extraction root is extracted with UnsafeSymlinkError.
+var ErrUnsafeSymlink = errors.New("Symbolic link points outside of the destination directory.")
+
+// ErrArchiveInProgress is returned when an archive is created while another
+// goroutine is still creating the same archive.
+var ErrArchiveInProgress = errors.New("Archive is already being created.")
+
+// ErrMergeDestination is returned by Merge when the destination is one of the
+// source archives.
+var ErrMergeDestination = errors.New("Merge destination is one of the sources.")
+
+// ErrInvalidDevice is returned when a device entry carries negative device
+// numbers.
+var ErrInvalidDevice = errors.New("Invalid device numbers.")
+
+// ErrNotIndexed is returned by OpenIndexed when an archive does not end with an
+// index.
+var ErrNotIndexed = errors.New("Archive has no index.")
+
+// ErrIndexCompressed is returned by CreateIndexed when compression is
+// requested as the index holds offsets into the uncompressed archive.
+var ErrIndexCompressed = errors.New("Cannot index compressed archives.")
+
+// ErrPAXRecordNotFound is returned when an archive does not contain the
+// requested PAX record.
+var ErrPAXRecordNotFound = errors.New("PAX record not found in archive.")
+
+// ErrPathTooDeep is returned when the name of an entry has more components
+// than allowed by WithMaxPathDepth.
+var ErrPathTooDeep = errors.New("Entry name is nested too deeply.")
+
+// ErrEntryNotFound is returned when an archive does not contain the requested
+// entry.
+var ErrEntryNotFound = errors.New("Entry not found in archive.")
+
+// ChecksumMismatchError is returned when the checksum computed over a tar
+// stream does not match the expected checksum.
+type ChecksumMismatchError struct {
+	Expected []byte
+	Actual   []byte
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("Expected checksum %x. Received %x instead.", e.Expected, e.Actual)
+}
+
+// EntryHashMismatchError is returned by extraction with WithVerifyEntryHash()
+// when the content of an entry does not match the hash stored with it. The
+// hashes are hex encoded.
+type EntryHashMismatchError struct {
+	Name     string
+	Expected string
+	Actual   string
+}
+
+func (e *EntryHashMismatchError) Error() string {
+	return fmt.Sprintf("%s: Expected checksum %s. Received %s instead.", e.Name, e.Expected, e.Actual)
+}
+
+// WriteVerificationError is returned by archive creation with
+// WithVerifyAfterWrite when the content of Entry read back from the archive
+// differs from what was written.
+type WriteVerificationError struct {
+	Entry    string
+	Expected []byte
+	Actual   []byte
+}
+
+func (e *WriteVerificationError) Error() string {
+	return fmt.Sprintf("%s: Expected checksum %x after write. Received %x instead.", e.Entry, e.Expected, e.Actual)
+}
+
+// XattrUnsupportedError is returned when the filesystem Path is located on
+// does not support extended attributes.
+type XattrUnsupportedError struct {
+	Path string
+	Err  error
+}
+
+func (e *XattrUnsupportedError) Error() string {
+	return fmt.Sprintf("%s: Extended attributes are not supported: %v", e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error, usually ENOTSUP.
+func (e *XattrUnsupportedError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError collects the errors encountered by operations that do not stop
+// at the first failure.
+type MultiError []error
+
+func (m MultiError) Error() string {
+	s := make([]string, len(m))
+	for i, err := range m {
+		s[i] = err.Error()
+	}
+
+	return strings.Join(s, "; ")
+}
+
+// Unwrap returns the collected errors so errors.Is and errors.As can inspect
+// them.
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
+// err returns nil if no error was collected, the error itself if exactly one
+// error was collected and the MultiError otherwise.
+func (m MultiError) err() error {
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	}
+
+	return m
+}
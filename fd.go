@@ -0,0 +1,212 @@
+package tarski
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io"
+	"os"
+	"strings"
+)
+
+// fdWriter writes to a file descriptor without taking ownership of it.
+type fdWriter int
+
+func (fd fdWriter) Write(p []byte) (n int, err error) {
+	for n < len(p) {
+		var m int
+		m, err = unix.Write(int(fd), p[n:])
+		if err != nil {
+			return
+		}
+		n += m
+	}
+
+	return
+}
+
+// CreateFromFd writes a tar archive of the directory referred to by dirFd to
+// archiveFd. dirFd may be opened with O_PATH. The tree is traversed relative
+// to dirFd without resolving any paths from the root of the filesystem and
+// without following symbolic links, so renaming or replacing the directory
+// while it is archived has no effect. Neither file descriptor is closed.
+//
+// Unlike the prefix given to Create, which is stripped from the archived
+// paths, prefix is prepended to all entries as a leading directory: entries
+// are named relative to dirFd below prefix, e.g. "root" or "root/" both yield
+// "root/file". An empty prefix archives the entries as they are named
+// relative to dirFd.
+//
+// Headers are created with the same options as Create, e.g. WithFormat,
+// WithPAXRecord or WithPathTransform, and the archive is compressed and padded
+// like one written by Create. Extended attributes are only archived for
+// regular files and directories.
+func CreateFromFd(archiveFd int, dirFd int, prefix string, opts ...Option) error {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	o := newOptions(opts)
+	return createStreamWith(fdWriter(archiveFd), nil, o, func(w *tar.Writer) error {
+		if err := writeGlobalHeader(w, o); err != nil {
+			return err
+		}
+
+		return walkFd(w, dirFd, prefix, o, newProgress(o.Progress, -1))
+	})
+}
+
+// openBeneath opens name in the directory dirFd. openat2 with RESOLVE_BENEATH
+// is used if the kernel supports it.
+func openBeneath(dirFd int, name string, flags int) (int, error) {
+	flags |= unix.O_NOFOLLOW | unix.O_CLOEXEC
+
+	fd, err := unix.Openat2(dirFd, name, &unix.OpenHow{
+		Flags:   uint64(flags),
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS,
+	})
+	if errors.Is(err, unix.ENOSYS) {
+		fd, err = unix.Openat(dirFd, name, flags, 0)
+	}
+
+	return fd, err
+}
+
+// walkFd archives the contents of the directory dirFd in lexical order. The
+// names of all entries start with entry.
+func walkFd(w *tar.Writer, dirFd int, entry string, o *Options, p *progress) error {
+	fd, err := openBeneath(dirFd, ".", unix.O_RDONLY|unix.O_DIRECTORY)
+	if err != nil {
+		return err
+	}
+	d := os.NewFile(uintptr(fd), entry)
+	defer d.Close()
+
+	dirents, err := d.ReadDir(-1)
+	if err != nil {
+		return err
+	}
+
+	for _, dirent := range dirents {
+		name := dirent.Name()
+		if o.ExcludeHidden && name[0] == '.' {
+			continue
+		}
+
+		var excluded bool
+		if excluded, err = o.excluded(entry + name); err != nil {
+			return err
+		}
+		if excluded {
+			continue
+		}
+
+		var st unix.Stat_t
+		if err = unix.Fstatat(fd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return err
+		}
+
+		if err = writeFdEntry(w, fd, name, entry+name, st.Mode&unix.S_IFMT, o, p); err != nil {
+			return fmt.Errorf("%s: %w", entry+name, err)
+		}
+	}
+
+	return nil
+}
+
+// writeFdEntry archives name in the directory dirFd under the name entry.
+// Regular files and directories are opened for reading, everything else only
+// as a path.
+func writeFdEntry(w *tar.Writer, dirFd int, name string, entry string, typ uint32, o *Options, p *progress) error {
+	flags := unix.O_PATH
+	switch typ {
+	case unix.S_IFREG:
+		flags = unix.O_RDONLY
+	case unix.S_IFDIR:
+		flags = unix.O_RDONLY | unix.O_DIRECTORY
+	}
+
+	fd, err := openBeneath(dirFd, name, flags)
+	if err != nil {
+		return err
+	}
+	f := os.NewFile(uintptr(fd), name)
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var link string
+	if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+		buf := make([]byte, unix.PathMax)
+		n, err := unix.Readlinkat(fd, "", buf)
+		if err != nil {
+			return err
+		}
+		link = string(buf[:n])
+	}
+
+	h, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		entry += "/"
+	}
+
+	// Children are named after the untransformed entry as they are when
+	// walking a path.
+	h.Name = entry
+	if o.PathTransform != nil {
+		if h.Name, err = transformEntryName(entry, fi, o); err != nil {
+			return err
+		}
+	}
+
+	if flags != unix.O_PATH {
+		raw, err := GetAllXattrFromFd(uintptr(fd))
+		if err != nil {
+			return err
+		}
+		h.Xattrs = xattrStrings(raw)
+	}
+
+	if err = headerOptions(h, entry, o); err != nil {
+		return err
+	}
+
+	if o.StoreBirthTime {
+		if err = storeBirthTime(h, fd, "", unix.AT_EMPTY_PATH); err != nil {
+			return err
+		}
+	}
+
+	if h.Name != "" {
+		if err = o.validatePath(h); err != nil {
+			return err
+		}
+
+		if err = w.WriteHeader(h); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case fi.IsDir():
+		p.start(entry, 0)
+		return walkFd(w, fd, entry, o, p)
+	case h.Name == "":
+		return nil
+	case fi.Mode().IsRegular():
+		p.start(h.Name, fi.Size())
+		_, err = io.Copy(p.writer(w), f)
+		return err
+	}
+
+	p.start(h.Name, 0)
+	return nil
+}
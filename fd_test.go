@@ -0,0 +1,220 @@
+package tarski
+
+import (
+	"archive/tar"
+	"golang.org/x/sys/unix"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCreateFromFd(t *testing.T) {
+	parent := t.TempDir()
+	src := filepath.Join(parent, "src")
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "dir", "file"), []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetXattr(filepath.Join(src, "dir", "file"), "user.test", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir/file", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	dirFd, err := unix.Open(src, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(dirFd)
+
+	// Replace the directory after it has been opened. The archive still has
+	// to contain the original tree.
+	if err = os.Rename(src, filepath.Join(parent, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(src, "replaced"), []byte("replaced"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := os.Create(filepath.Join(t.TempDir(), archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if err = CreateFromFd(int(a.Fd()), dirFd, "root"); err != nil {
+		t.Fatal(err)
+	}
+
+	names := readEntryNames(t, a.Name())
+	expected := []string{"root/dir/", "root/dir/file", "root/link"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Received %v instead.", expected, names)
+	}
+
+	if _, err = a.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	r := tar.NewReader(a)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch h.Name {
+		case "root/dir/file":
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "original" {
+				t.Fatalf("Expected content %q. Received %q instead.", "original", data)
+			}
+			if h.Xattrs["user.test"] != "dmFsdWU=" {
+				t.Fatalf("Expected base64 encoded xattr user.test=value. Received %v instead.", h.Xattrs)
+			}
+		case "root/link":
+			if h.Typeflag != tar.TypeSymlink || h.Linkname != "dir/file" {
+				t.Fatalf("Expected a symbolic link to dir/file. Received type %q to %s instead.", h.Typeflag, h.Linkname)
+			}
+		}
+	}
+}
+
+func TestCreateFromFdExcludeGlob(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{".git/config", "dir/debug.log", "dir/file"} {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dirFd, err := unix.Open(src, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(dirFd)
+
+	a, err := os.Create(filepath.Join(t.TempDir(), archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if err = CreateFromFd(int(a.Fd()), dirFd, "", WithExcludeGlob(".git", "*.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	names := readEntryNames(t, a.Name())
+	expected := []string{"dir/", "dir/file"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Received %v instead.", expected, names)
+	}
+}
+
+func TestCreateFromFdHeaderOptions(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dirFd, err := unix.Open(src, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(dirFd)
+
+	a, err := os.Create(filepath.Join(t.TempDir(), archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	err = CreateFromFd(int(a.Fd()), dirFd, "",
+		WithFormat(tar.FormatPAX),
+		WithPAXRecord("tarski.origin", "fd"),
+		WithIDMap(func(int) int { return 4242 }, nil),
+		WithPathTransform(func(name string) (string, error) { return "renamed/" + name, nil }))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(a.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	h, err := tar.NewReader(f).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Name != "renamed/file" {
+		t.Fatalf("Expected entry renamed/file. Received %s instead.", h.Name)
+	}
+	if h.Format != tar.FormatPAX || h.PAXRecords["tarski.origin"] != "fd" {
+		t.Fatalf("Expected a PAX header carrying tarski.origin. Received format %v with %v instead.", h.Format, h.PAXRecords)
+	}
+	if h.Uid != 4242 {
+		t.Fatalf("Expected owner 4242. Received %d instead.", h.Uid)
+	}
+}
+
+func TestCreateFromFdCompressed(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dirFd, err := unix.Open(src, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(dirFd)
+
+	a, err := os.Create(filepath.Join(t.TempDir(), archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	if err = CreateFromFd(int(a.Fd()), dirFd, "root/", WithGzip()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(a.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := detectCompression(data); format != CompressionGzip {
+		t.Fatalf("Expected a gzip compressed archive. Received %v instead.", format)
+	}
+
+	dest := t.TempDir()
+	if err = Extract(a.Name(), dest); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dest, "root", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "data" {
+		t.Fatalf("Expected content %q. Received %q instead.", "data", content)
+	}
+}
@@ -0,0 +1,93 @@
+package tarski
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"strings"
+)
+
+// FileEntry describes a single file to be archived by CreateFromFiles.
+// Src is the file on disk and Entry its name in the archive. If Xattrs is
+// not nil it replaces the extended attributes found on Src.
+type FileEntry struct {
+	Src    string
+	Entry  string
+	Xattrs map[string][]byte
+}
+
+// CreateFromFiles creates a tar archive containing exactly the given files.
+// Unlike Create no directories are walked. Entries are written in the order
+// given.
+func CreateFromFiles(archive string, files []FileEntry, opts ...Option) error {
+	o := newOptions(opts)
+	return createArchiveWith(archive, nil, o, func(w *tar.Writer) error {
+		return writeFiles(w, files, o)
+	})
+}
+
+// CreateFromFilesStream writes a tar archive containing exactly the given
+// files to w.
+func CreateFromFilesStream(w io.Writer, files []FileEntry, opts ...Option) error {
+	o := newOptions(opts)
+	return createStreamWith(w, nil, o, func(tw *tar.Writer) error {
+		return writeFiles(tw, files, o)
+	})
+}
+
+// writeFiles writes the global header followed by an entry for each of files.
+func writeFiles(w *tar.Writer, files []FileEntry, o *Options) error {
+	if err := writeGlobalHeader(w, o); err != nil {
+		return err
+	}
+
+	var p *progress
+	if o.Progress != nil {
+		p = newProgress(o.Progress, len(files))
+	}
+
+	for _, file := range files {
+		if err := writeFileEntry(w, file, o, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeFileEntry(w *tar.Writer, file FileEntry, o *Options, p *progress) error {
+	f, err := os.Lstat(file.Src)
+	if err != nil {
+		return err
+	}
+
+	entry := file.Entry
+	if f.IsDir() && !strings.HasSuffix(entry, "/") {
+		entry = entry + "/"
+	}
+
+	h, err := fileHeader(file.Src, entry, f, o, nil)
+	if err != nil {
+		return err
+	}
+
+	if file.Xattrs != nil {
+		h.Xattrs = make(map[string]string, len(file.Xattrs))
+		for k, v := range file.Xattrs {
+			h.Xattrs[k] = string(v)
+		}
+		if err = o.XattrEncoding.encodeHeader(h); err != nil {
+			return err
+		}
+	}
+
+	if err = o.validatePath(h); err != nil {
+		return err
+	}
+
+	if err = w.WriteHeader(h); err != nil {
+		return err
+	}
+
+	return writeContent(w, file.Src, entry, f, o, p)
+}
@@ -0,0 +1,133 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCreateFromFiles(t *testing.T) {
+	a := t.TempDir()
+	b := filepath.Join(t.TempDir(), "nested", "dir")
+	if err := os.MkdirAll(b, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(a, "one"), []byte("one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(b, "two"), []byte("two"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []FileEntry{
+		{Src: filepath.Join(b, "two"), Entry: "first"},
+		{Src: filepath.Join(a, "one"), Entry: "second", Xattrs: map[string][]byte{"user.test": []byte("value")}},
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := CreateFromFiles(tarball, files); err != nil {
+		t.Fatal(err)
+	}
+
+	names := readEntryNames(t, tarball)
+	expected := []string{"first", "second"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Received %v instead.", expected, names)
+	}
+
+	dest := t.TempDir()
+	if err := Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range map[string]string{"first": "two", "second": "one"} {
+		data, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("Expected content %q for %s. Received %q instead.", content, name, data)
+		}
+	}
+
+	fi, err := os.Stat(filepath.Join(dest, "first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("Expected mode 0600. Received %o instead.", fi.Mode().Perm())
+	}
+}
+
+func TestCreateFromFilesStream(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(src, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	files := []FileEntry{{Src: src, Entry: "dir/file", Xattrs: map[string][]byte{"user.test": []byte("value")}}}
+	if err := CreateFromFilesStream(&buf, files); err != nil {
+		t.Fatal(err)
+	}
+
+	r := tar.NewReader(&buf)
+	h, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Name != "dir/file" {
+		t.Fatalf("Expected entry dir/file. Received %s instead.", h.Name)
+	}
+	if h.Xattrs["user.test"] != "dmFsdWU=" {
+		t.Fatalf("Expected base64 encoded xattr user.test=value. Received %v instead.", h.Xattrs)
+	}
+
+	if _, err = r.Next(); err != io.EOF {
+		t.Fatalf("Expected a single entry. Received %v instead.", err)
+	}
+}
+
+func TestCreateFromFilesCompressed(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(src, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := CreateFromFiles(tarball, []FileEntry{{Src: src, Entry: "file"}}, WithGzip()); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := detectCompression(compressed); c != CompressionGzip {
+		t.Fatalf("Expected a gzip compressed archive. Received %s instead.", c)
+	}
+
+	dest := t.TempDir()
+	if err = Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "data" {
+		t.Fatalf("Expected content %q. Received %q instead.", "data", data)
+	}
+
+	var buf bytes.Buffer
+	if err = CreateFromFilesStream(&buf, []FileEntry{{Src: src, Entry: "file"}}, WithRecordSize(10240)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len()%10240 != 0 {
+		t.Fatalf("Expected the stream to be padded to 10240 bytes. Received %d bytes instead.", buf.Len())
+	}
+}
@@ -0,0 +1,200 @@
+package tarski
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CompressionFormat describes the compression applied to a tar archive.
+type CompressionFormat int
+
+const (
+	// CompressionNone indicates an uncompressed tar archive.
+	CompressionNone CompressionFormat = iota
+	// CompressionGzip indicates a gzip compressed tar archive.
+	CompressionGzip
+	// CompressionZstd indicates a zstd compressed tar archive.
+	CompressionZstd
+	// CompressionBzip2 indicates a bzip2 compressed tar archive.
+	CompressionBzip2
+	// CompressionXZ indicates a xz compressed tar archive.
+	CompressionXZ
+)
+
+func (c CompressionFormat) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	case CompressionBzip2:
+		return "bzip2"
+	case CompressionXZ:
+		return "xz"
+	}
+
+	return "CompressionFormat(" + strconv.Itoa(int(c)) + ")"
+}
+
+// TarFormat describes the format of the first header of a tar archive.
+type TarFormat int
+
+const (
+	// TarFormatUnknown indicates that the tar format could not be detected.
+	TarFormatUnknown TarFormat = iota
+	// TarFormatV7 indicates the original Unix V7 tar format.
+	TarFormatV7
+	// TarFormatUSTAR indicates the POSIX.1-1988 ustar format.
+	TarFormatUSTAR
+	// TarFormatPAX indicates the POSIX.1-2001 pax format.
+	TarFormatPAX
+	// TarFormatGNU indicates the GNU tar format.
+	TarFormatGNU
+)
+
+func (f TarFormat) String() string {
+	switch f {
+	case TarFormatUnknown:
+		return "unknown"
+	case TarFormatV7:
+		return "v7"
+	case TarFormatUSTAR:
+		return "ustar"
+	case TarFormatPAX:
+		return "pax"
+	case TarFormatGNU:
+		return "gnu"
+	}
+
+	return "TarFormat(" + strconv.Itoa(int(f)) + ")"
+}
+
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicBzip2 = []byte{0x42, 0x5a, 0x68}
+	magicXZ    = []byte{0xfd, 0x37, 0x7a, 0x58, 0x5a, 0x00}
+
+	magicUSTAR = []byte("ustar\x0000")
+	magicGNU   = []byte("ustar  \x00")
+)
+
+// DetectFormat detects the compression format of an archive by its magic
+// bytes. For uncompressed archives the tar format of the first header is
+// detected as well. It is TarFormatUnknown for compressed archives.
+func DetectFormat(archive string) (CompressionFormat, TarFormat, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return CompressionNone, TarFormatUnknown, err
+	}
+	defer f.Close()
+
+	b := make([]byte, blockSize)
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return CompressionNone, TarFormatUnknown, err
+	}
+	b = b[:n]
+
+	c := detectCompression(b)
+	if c != CompressionNone {
+		return c, TarFormatUnknown, nil
+	}
+
+	return c, detectTarFormat(b), nil
+}
+
+// IsGzipped detects gzip compressed archives.
+func IsGzipped(archive string) (bool, error) {
+	return isCompressedWith(archive, CompressionGzip)
+}
+
+// IsZstd detects zstd compressed archives.
+func IsZstd(archive string) (bool, error) {
+	return isCompressedWith(archive, CompressionZstd)
+}
+
+// IsBzip2 detects bzip2 compressed archives.
+func IsBzip2(archive string) (bool, error) {
+	return isCompressedWith(archive, CompressionBzip2)
+}
+
+// IsXZ detects xz compressed archives.
+func IsXZ(archive string) (bool, error) {
+	return isCompressedWith(archive, CompressionXZ)
+}
+
+func isCompressedWith(archive string, want CompressionFormat) (bool, error) {
+	c, _, err := DetectFormat(archive)
+	if err != nil {
+		return false, err
+	}
+
+	return c == want, nil
+}
+
+func detectCompression(b []byte) CompressionFormat {
+	switch {
+	case bytes.HasPrefix(b, magicGzip):
+		return CompressionGzip
+	case bytes.HasPrefix(b, magicZstd):
+		return CompressionZstd
+	case bytes.HasPrefix(b, magicBzip2):
+		return CompressionBzip2
+	case bytes.HasPrefix(b, magicXZ):
+		return CompressionXZ
+	}
+
+	return CompressionNone
+}
+
+// detectTarFormat inspects the magic of a tar header block. Blocks without a
+// magic are only reported as V7 if their header checksum is valid.
+func detectTarFormat(b []byte) TarFormat {
+	if len(b) < blockSize {
+		return TarFormatUnknown
+	}
+
+	switch magic := b[257:265]; {
+	case bytes.Equal(magic, magicGNU):
+		return TarFormatGNU
+	case bytes.Equal(magic, magicUSTAR):
+		// Extended headers are only defined by the pax format.
+		if b[156] == 'x' || b[156] == 'g' {
+			return TarFormatPAX
+		}
+		return TarFormatUSTAR
+	}
+
+	if validChecksum(b) {
+		return TarFormatV7
+	}
+
+	return TarFormatUnknown
+}
+
+// validChecksum verifies the checksum of a tar header block. The checksum is
+// the sum of all header bytes with the checksum field itself treated as
+// spaces.
+func validChecksum(b []byte) bool {
+	field := strings.Trim(string(b[148:156]), " \x00")
+	want, err := strconv.ParseInt(field, 8, 64)
+	if err != nil {
+		return false
+	}
+
+	var sum int64
+	for i, c := range b[:blockSize] {
+		if i >= 148 && i < 156 {
+			c = ' '
+		}
+		sum += int64(c)
+	}
+
+	return sum == want
+}
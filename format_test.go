@@ -0,0 +1,84 @@
+package tarski
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	dir := t.TempDir()
+
+	gz := filepath.Join(dir, "archive.tar.gz")
+	f, err := os.Create(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := gzip.NewWriter(f)
+	if _, err = w.Write(make([]byte, 2*blockSize)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	magic := map[string][]byte{
+		"archive.tar.zst": magicZstd,
+		"archive.tar.bz2": append(append([]byte(nil), magicBzip2...), '9'),
+		"archive.tar.xz":  magicXZ,
+	}
+	for name, b := range magic {
+		if err = os.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		archive     string
+		compression CompressionFormat
+		format      TarFormat
+	}{
+		{gz, CompressionGzip, TarFormatUnknown},
+		{filepath.Join(dir, "archive.tar.zst"), CompressionZstd, TarFormatUnknown},
+		{filepath.Join(dir, "archive.tar.bz2"), CompressionBzip2, TarFormatUnknown},
+		{filepath.Join(dir, "archive.tar.xz"), CompressionXZ, TarFormatUnknown},
+		{writeFormatArchive(t, tar.FormatUSTAR), CompressionNone, TarFormatUSTAR},
+		{writeFormatArchive(t, tar.FormatGNU), CompressionNone, TarFormatGNU},
+		{writeFormatArchive(t, tar.FormatPAX), CompressionNone, TarFormatPAX},
+	}
+
+	for _, test := range tests {
+		c, f, err := DetectFormat(test.archive)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != test.compression || f != test.format {
+			t.Fatalf("Expected %s to be detected as %s/%s. Received %s/%s instead.", test.archive, test.compression, test.format, c, f)
+		}
+	}
+
+	ok, err := IsGzipped(gz)
+	if err != nil || !ok {
+		t.Fatalf("Expected %s to be gzip compressed.", gz)
+	}
+	ok, err = IsZstd(gz)
+	if err != nil || ok {
+		t.Fatalf("Expected %s not to be zstd compressed.", gz)
+	}
+	ok, err = IsBzip2(filepath.Join(dir, "archive.tar.bz2"))
+	if err != nil || !ok {
+		t.Fatalf("Expected archive.tar.bz2 to be bzip2 compressed.")
+	}
+}
+
+func writeFormatArchive(t *testing.T, format tar.Format) string {
+	h := &tar.Header{Typeflag: tar.TypeReg, Name: "file", Mode: 0644, Format: format}
+	if format == tar.FormatPAX {
+		h.PAXRecords = map[string]string{"tarski.test": "pax"}
+	}
+
+	return writeTestArchive(t, []testEntry{{header: h, content: "content"}})
+}
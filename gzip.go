@@ -0,0 +1,27 @@
+package tarski
+
+// CreateGzip creates a gzip compressed tar archive.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateGzip(archive string, path string, prefix string, opts ...Option) error {
+	return Create(archive, path, prefix, withCompression(CompressionGzip, opts)...)
+}
+
+// CreateGzipSHA256 creates a gzip compressed tar archive and returns the
+// SHA256-hash checksum of the uncompressed tar stream.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateGzipSHA256(archive string, path string, prefix string, opts ...Option) ([]byte, error) {
+	return CreateSHA256(archive, path, prefix, withCompression(CompressionGzip, opts)...)
+}
+
+// ExtractGzip extracts a gzip compressed tar archive under path.
+func ExtractGzip(archive string, path string, opts ...Option) error {
+	return Extract(archive, path, withCompression(CompressionGzip, opts)...)
+}
+
+// ExtractGzipSHA256 extracts a gzip compressed tar archive under path and
+// returns the SHA256-hash checksum of the uncompressed tar stream.
+func ExtractGzipSHA256(archive string, path string, opts ...Option) ([]byte, error) {
+	return ExtractSHA256(archive, path, withCompression(CompressionGzip, opts)...)
+}
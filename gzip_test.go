@@ -0,0 +1,56 @@
+package tarski
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGzip(t *testing.T) {
+	uncompressed := filepath.Join(t.TempDir(), archive)
+	expected, err := CreateSHA256(uncompressed, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive+".gz")
+	if err = CreateGzip(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := IsGzipped(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Expected a gzip compressed archive.")
+	}
+
+	dest := t.TempDir()
+	if err = ExtractGzip(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dest, entries[6])); err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := CreateGzipSHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected the checksum %x of the uncompressed stream. Received %x instead.", expected, checksum)
+	}
+
+	checksum, err = ExtractGzipSHA256(tarball, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected the checksum %x of the uncompressed stream. Received %x instead.", expected, checksum)
+	}
+
+	if err = ExtractGzip(uncompressed, t.TempDir()); err == nil {
+		t.Fatal("Expected extracting an uncompressed archive with ExtractGzip to fail.")
+	}
+}
@@ -0,0 +1,133 @@
+package tarski
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"crypto/sha3"
+	"crypto/sha512"
+	"errors"
+	"github.com/zeebo/blake3"
+	"hash"
+	"io"
+)
+
+// HashAlgorithm selects the hash function used to compute content hashes.
+type HashAlgorithm int
+
+const (
+	// HashSHA256 selects SHA-256.
+	HashSHA256 HashAlgorithm = iota
+	// HashSHA512 selects SHA-512.
+	HashSHA512
+	// HashSHA3256 selects SHA3-256.
+	HashSHA3256
+	// HashBLAKE3 selects BLAKE3 with a 256 bit output.
+	HashBLAKE3
+)
+
+// ErrUnknownHashAlgorithm is returned when an unsupported HashAlgorithm is
+// requested.
+var ErrUnknownHashAlgorithm = errors.New("Unknown hash algorithm.")
+
+// New returns a new hash.Hash computing algo.
+func (algo HashAlgorithm) New() (hash.Hash, error) {
+	switch algo {
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	case HashSHA3256:
+		return sha3.New256(), nil
+	case HashBLAKE3:
+		return blake3.New(), nil
+	}
+
+	return nil, ErrUnknownHashAlgorithm
+}
+
+// CreateWithHash creates a tar archive and returns the checksum of the tar
+// stream computed by h. h is expected to be freshly created or reset.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateWithHash(archive string, path string, prefix string, h hash.Hash, opts ...Option) ([]byte, error) {
+	if err := createArchive(archive, path, prefix, h, newOptions(opts)); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// ExtractWithHash extracts a tar archive under path and returns the checksum of
+// the uncompressed tar stream computed by h. h is expected to be freshly created
+// or reset.
+func ExtractWithHash(archive string, path string, h hash.Hash, opts ...Option) ([]byte, error) {
+	if err := extractArchiveFile(archive, path, h, newOptions(opts)); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// CreateSHA512 creates a tar archive and returns its SHA512-hash checksum.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateSHA512(archive string, path string, prefix string, opts ...Option) ([]byte, error) {
+	return CreateWithHash(archive, path, prefix, sha512.New(), opts...)
+}
+
+// ExtractSHA512 extracts a tar archive under path and returns its SHA512-hash
+// checksum.
+func ExtractSHA512(archive string, path string, opts ...Option) ([]byte, error) {
+	return ExtractWithHash(archive, path, sha512.New(), opts...)
+}
+
+// CreateSHA3256 creates a tar archive and returns its SHA3-256-hash checksum.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateSHA3256(archive string, path string, prefix string, opts ...Option) ([]byte, error) {
+	return CreateWithHash(archive, path, prefix, sha3.New256(), opts...)
+}
+
+// ExtractSHA3256 extracts a tar archive under path and returns its
+// SHA3-256-hash checksum.
+func ExtractSHA3256(archive string, path string, opts ...Option) ([]byte, error) {
+	return ExtractWithHash(archive, path, sha3.New256(), opts...)
+}
+
+// WalkAndHash computes the content hash of the directory tree at path without
+// creating an archive. The tar stream Create would produce for path with path
+// as prefix is fed into the hash instead of a file so the result for
+// HashSHA256 is identical to the checksum returned by CreateSHA256.
+func WalkAndHash(path string, algo HashAlgorithm, opts ...Option) ([]byte, error) {
+	h, err := algo.New()
+	if err != nil {
+		return nil, err
+	}
+
+	w := tar.NewWriter(h)
+	if err = doCreate(w, path, path, newOptions(opts)); err != nil {
+		return nil, err
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// CopyArchive copies src to dst and returns the hash of the copied bytes. No
+// tar parsing takes place so any archive, compressed or not, can be copied.
+// For files on disk the result for HashSHA256 matches CalculateArchiveSHA256.
+func CopyArchive(dst io.Writer, src io.Reader, algo HashAlgorithm) ([]byte, error) {
+	h, err := algo.New()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = io.Copy(dst, io.TeeReader(src, h)); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
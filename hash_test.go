@@ -0,0 +1,128 @@
+package tarski
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWalkAndHash(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	expected, err := CreateSHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := WalkAndHash(prefix, HashSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected checksum %x. Received %x instead.", expected, checksum)
+	}
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	h := sha512.New()
+	if _, err = io.Copy(h, f); err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err = WalkAndHash(prefix, HashSHA512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, h.Sum(nil)) {
+		t.Fatalf("Expected checksum %x. Received %x instead.", h.Sum(nil), checksum)
+	}
+
+	if _, err = WalkAndHash(prefix, HashAlgorithm(-1)); !errors.Is(err, ErrUnknownHashAlgorithm) {
+		t.Fatalf("Expected ErrUnknownHashAlgorithm. Received %v instead.", err)
+	}
+}
+
+func TestCopyArchive(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, prefix, prefix, WithBzip2()); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	checksum, err := CopyArchive(&buf, f, HashSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("Expected the copy to match the archive.")
+	}
+
+	expected, err := CalculateArchiveSHA256(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected checksum %x. Received %x instead.", expected, checksum)
+	}
+}
+
+func TestCreateExtractWithHash(t *testing.T) {
+	for _, tc := range []struct {
+		algo    HashAlgorithm
+		create  func(string, string, string, ...Option) ([]byte, error)
+		extract func(string, string, ...Option) ([]byte, error)
+	}{
+		{HashSHA256, CreateSHA256, ExtractSHA256},
+		{HashSHA512, CreateSHA512, ExtractSHA512},
+		{HashSHA3256, CreateSHA3256, ExtractSHA3256},
+		{HashBLAKE3, CreateBLAKE3, ExtractBLAKE3},
+	} {
+		expected, err := WalkAndHash(prefix, tc.algo)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tarball := filepath.Join(t.TempDir(), archive)
+		created, err := tc.create(tarball, prefix, prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(created, expected) {
+			t.Fatalf("Expected checksum %x. Received %x instead.", expected, created)
+		}
+
+		extracted, err := tc.extract(tarball, t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(extracted, expected) {
+			t.Fatalf("Expected checksum %x. Received %x instead.", expected, extracted)
+		}
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	created, err := CreateWithHash(tarball, prefix, prefix, sha512.New384())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != sha512.Size384 {
+		t.Fatalf("Expected a %d byte checksum. Received %d bytes instead.", sha512.Size384, len(created))
+	}
+}
@@ -0,0 +1,164 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// GNUIncrementalManifest describes an incremental backup created by GNU tar
+// with --listed-incremental. All names are relative to the archive root.
+type GNUIncrementalManifest struct {
+	// Directories lists the directories carrying a dump directory listing.
+	Directories []string
+	// Dumped lists the files whose content is stored in the archive because
+	// they were added or modified since the previous backup.
+	Dumped []string
+	// Unchanged lists the files that still existed but were not modified
+	// since the previous backup.
+	Unchanged []string
+	// Renamed maps the old names of renamed directories to their new names.
+	Renamed map[string]string
+}
+
+// ReadGNUIncremental parses the dump directory entries of a GNU tar incremental
+// archive. An archive without such entries yields an empty manifest.
+// The archive alone cannot tell new and modified files apart nor which files
+// were removed. Use Changes to compare the manifest with the tree it is to be
+// restored onto.
+func ReadGNUIncremental(archive string) (*GNUIncrementalManifest, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, _, err := decompressReader(f, &Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	m := &GNUIncrementalManifest{Renamed: make(map[string]string)}
+	r := tar.NewReader(c)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if h.Typeflag != typeGNUDumpDir {
+			continue
+		}
+
+		listing, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		if err = m.parseDumpDir(h.Name, listing); err != nil {
+			return nil, fmt.Errorf("entry %q: %w", h.Name, err)
+		}
+	}
+
+	return m, nil
+}
+
+// parseDumpDir parses the NUL-terminated records of the listing of dir. Each
+// record starts with a control character:
+//
+//	Y  file stored in the archive
+//	N  file not stored in the archive
+//	D  subdirectory
+//	R  old name of a renamed directory, followed by a T record
+//	T  new name of a renamed directory
+//	X  temporary name used while renaming
+func (m *GNUIncrementalManifest) parseDumpDir(dir string, listing []byte) error {
+	m.Directories = append(m.Directories, dir)
+
+	var from string
+	for _, record := range bytes.Split(listing, []byte{0}) {
+		if len(record) == 0 {
+			continue
+		}
+
+		name := string(record[1:])
+		switch record[0] {
+		case 'Y':
+			m.Dumped = append(m.Dumped, path.Join(dir, name))
+		case 'N':
+			m.Unchanged = append(m.Unchanged, path.Join(dir, name))
+		case 'D', 'X':
+		case 'R':
+			from = name
+		case 'T':
+			if from == "" {
+				return fmt.Errorf("Rename target %q without source.", name)
+			}
+			m.Renamed[from] = name
+			from = ""
+		default:
+			return fmt.Errorf("Unknown dump directory record %q.", record)
+		}
+	}
+
+	return nil
+}
+
+// Changes compares the manifest with the tree at root the backup is to be
+// restored onto. Added lists the dumped files missing under root, modified the
+// dumped files that exist and removed the files in the listed directories
+// under root that are unknown to the backup.
+func (m *GNUIncrementalManifest) Changes(root string) (added, modified, removed []string, err error) {
+	known := make(map[string]bool)
+	for _, name := range m.Dumped {
+		known[name] = true
+
+		_, err = os.Lstat(filepath.Join(root, name))
+		switch {
+		case err == nil:
+			modified = append(modified, name)
+		case os.IsNotExist(err):
+			added = append(added, name)
+		default:
+			return nil, nil, nil, err
+		}
+	}
+	err = nil
+
+	for _, name := range m.Unchanged {
+		known[name] = true
+	}
+	for _, dir := range m.Directories {
+		known[path.Clean(dir)] = true
+	}
+	for _, name := range m.Renamed {
+		known[path.Clean(name)] = true
+	}
+
+	for _, dir := range m.Directories {
+		dirents, err := os.ReadDir(filepath.Join(root, dir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		for _, d := range dirents {
+			if name := path.Join(dir, d.Name()); !known[name] {
+				removed = append(removed, name)
+			}
+		}
+	}
+	sort.Strings(removed)
+
+	return
+}
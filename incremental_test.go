@@ -0,0 +1,79 @@
+package tarski
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadGNUIncremental(t *testing.T) {
+	dumpdir := func(name string, listing string) testEntry {
+		return testEntry{&tar.Header{Typeflag: typeGNUDumpDir, Name: name, Mode: 0755, Size: int64(len(listing)), Format: tar.FormatGNU}, listing}
+	}
+
+	tarball := writeTestArchive(t, []testEntry{
+		dumpdir("dir/", "Ynew\x00Ychanged\x00Nsame\x00Dsub\x00Rdir/old\x00Tdir/sub\x00\x00"),
+		dumpdir("dir/sub/", "Nkept\x00\x00"),
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/new", Mode: 0644, Format: tar.FormatGNU}, "new"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/changed", Mode: 0644, Format: tar.FormatGNU}, "changed"},
+	})
+
+	m, err := ReadGNUIncremental(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &GNUIncrementalManifest{
+		Directories: []string{"dir/", "dir/sub/"},
+		Dumped:      []string{"dir/new", "dir/changed"},
+		Unchanged:   []string{"dir/same", "dir/sub/kept"},
+		Renamed:     map[string]string{"dir/old": "dir/sub"},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("Expected %+v. Received %+v instead.", expected, m)
+	}
+
+	root := t.TempDir()
+	if err = os.MkdirAll(filepath.Join(root, "dir", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dir/changed", "dir/same", "dir/gone", "dir/sub/kept", "dir/sub/stale"} {
+		if err = os.WriteFile(filepath.Join(root, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	added, modified, removed, err := m.Changes(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(added, []string{"dir/new"}) {
+		t.Fatalf("Expected dir/new to be added. Received %v instead.", added)
+	}
+	if !reflect.DeepEqual(modified, []string{"dir/changed"}) {
+		t.Fatalf("Expected dir/changed to be modified. Received %v instead.", modified)
+	}
+	if !reflect.DeepEqual(removed, []string{"dir/gone", "dir/sub/stale"}) {
+		t.Fatalf("Expected dir/gone and dir/sub/stale to be removed. Received %v instead.", removed)
+	}
+
+	dest := t.TempDir()
+	if err = Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(filepath.Join(dest, "dir", "sub")); err != nil || !fi.IsDir() {
+		t.Fatalf("Expected dump directories to be extracted as directories. Received %v instead.", err)
+	}
+}
+
+func TestReadGNUIncrementalInvalid(t *testing.T) {
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: typeGNUDumpDir, Name: "dir/", Mode: 0755, Size: 5, Format: tar.FormatGNU}, "Qbad\x00"},
+	})
+
+	if _, err := ReadGNUIncremental(tarball); err == nil {
+		t.Fatalf("Expected an unknown record to be rejected.")
+	}
+}
@@ -0,0 +1,240 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// indexEntry is the name of the entry holding the index written by
+// CreateIndexed.
+const indexEntry = "tarski.index"
+
+// CreateIndexed creates a tar archive whose last entry is a regular file named
+// tarski.index. It holds one "<offset> <name>" line per entry sorted by name
+// where offset is the position of the first header block of the entry. The
+// index allows OpenIndexed to look up entries without reading the whole
+// archive. Extraction restores the index as a regular file. Entry names must
+// not contain newlines. As the offsets refer to the uncompressed archive
+// ErrIndexCompressed is returned if compression is requested.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateIndexed(archive string, path string, prefix string, opts ...Option) (err error) {
+	o := newOptions(opts)
+	if o.Compression != CompressionNone {
+		return fmt.Errorf("%s: %w", archive, ErrIndexCompressed)
+	}
+
+	// The copy of the tar stream only counts the bytes written so far.
+	n := &countingWriter{w: io.Discard}
+	return createArchiveWith(archive, n, o, func(w *tar.Writer) error {
+		if err := doCreate(w, path, prefix, o); err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		f, err := os.Open(archive)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		index, err := buildIndex(io.NewSectionReader(f, 0, n.n))
+		if err != nil {
+			return err
+		}
+
+		err = w.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     indexEntry,
+			Mode:     0644,
+			Size:     int64(len(index)),
+			Format:   tar.FormatUSTAR,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(index)
+		return err
+	})
+}
+
+// buildIndex returns the index of the entries of the tar stream r.
+func buildIndex(r io.Reader) ([]byte, error) {
+	c := &countingReader{r: r}
+	t := tar.NewReader(c)
+
+	var lines []string
+	var offset int64
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.Contains(h.Name, "\n") {
+			return nil, fmt.Errorf("%q: %w", h.Name, ErrInvalidTarPath)
+		}
+		lines = append(lines, strconv.FormatInt(offset, 10)+" "+h.Name)
+
+		// Entries start at the block following the data of the previous
+		// one.
+		if _, err = io.Copy(io.Discard, t); err != nil {
+			return nil, err
+		}
+		offset = (c.n + blockSize - 1) / blockSize * blockSize
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i][strings.IndexByte(lines[i], ' ')+1:] < lines[j][strings.IndexByte(lines[j], ' ')+1:]
+	})
+
+	var b bytes.Buffer
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes(), nil
+}
+
+// IndexedArchive provides random access to the entries of an archive created
+// by CreateIndexed.
+type IndexedArchive struct {
+	f       *os.File
+	size    int64
+	names   []string
+	offsets []int64
+}
+
+// OpenIndexed opens an archive created by CreateIndexed and reads its index.
+// Only the trailing blocks of the archive are read. ErrNotIndexed is returned
+// if the archive does not end with an index. The archive needs to be closed
+// with Close.
+func OpenIndexed(archive string) (*IndexedArchive, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := readIndex(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// readIndex searches backwards from the end of archive marker for the header
+// of the index entry and parses the index.
+func readIndex(f *os.File) (*IndexedArchive, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+
+	b := make([]byte, blockSize)
+	zero := make([]byte, blockSize)
+
+	// Skip the end of archive marker and any padding.
+	end := size / blockSize * blockSize
+	for ; end > 0; end -= blockSize {
+		if _, err = f.ReadAt(b, end-blockSize); err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(b, zero) {
+			break
+		}
+	}
+
+	for off := end - blockSize; off >= 0; off -= blockSize {
+		if _, err = f.ReadAt(b, off); err != nil {
+			return nil, err
+		}
+		if b[156] != tar.TypeReg || string(bytes.TrimRight(b[:100], "\x00")) != indexEntry || !validChecksum(b) {
+			continue
+		}
+
+		r := tar.NewReader(io.NewSectionReader(f, off, end-off))
+		h, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if off+blockSize+(h.Size+blockSize-1)/blockSize*blockSize != end {
+			continue
+		}
+
+		a := &IndexedArchive{f: f, size: size}
+		if err = a.parse(r); err != nil {
+			return nil, err
+		}
+
+		return a, nil
+	}
+
+	return nil, ErrNotIndexed
+}
+
+// parse reads the "<offset> <name>" lines of an index.
+func (a *IndexedArchive) parse(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		offset, name, ok := strings.Cut(s.Text(), " ")
+		if !ok {
+			return fmt.Errorf("Invalid index line %q.", s.Text())
+		}
+
+		n, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		a.names = append(a.names, name)
+		a.offsets = append(a.offsets, n)
+	}
+
+	return s.Err()
+}
+
+// Names returns the sorted names of all indexed entries.
+func (a *IndexedArchive) Names() []string {
+	return append([]string(nil), a.names...)
+}
+
+// Get returns the header and content of the entry called name.
+// ErrEntryNotFound is returned if the archive does not contain the entry. The
+// content is only valid until the archive is closed.
+func (a *IndexedArchive) Get(name string) (*tar.Header, io.ReadCloser, error) {
+	i := sort.SearchStrings(a.names, name)
+	if i == len(a.names) || a.names[i] != name {
+		return nil, nil, fmt.Errorf("%s: %w", name, ErrEntryNotFound)
+	}
+
+	r := tar.NewReader(io.NewSectionReader(a.f, a.offsets[i], a.size-a.offsets[i]))
+	h, err := r.Next()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return h, io.NopCloser(r), nil
+}
+
+// Close closes the archive.
+func (a *IndexedArchive) Close() error {
+	return a.f.Close()
+}
@@ -0,0 +1,145 @@
+package tarski
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIndexedArchive(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "dir", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a":           "first",
+		"dir/b":       "second",
+		"dir/sub/c":   string(make([]byte, 3*blockSize+7)),
+		"dir/sub/z.d": "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := CreateIndexed(tarball, src, src); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := OpenIndexed(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	expected := []string{"a", "dir/", "dir/b", "dir/sub/", "dir/sub/c", "dir/sub/z.d"}
+	if names := a.Names(); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected index %v. Received %v instead.", expected, names)
+	}
+
+	for name, content := range files {
+		h, r, err := a.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Name != name {
+			t.Fatalf("Expected header of %s. Received %s instead.", name, h.Name)
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+		if string(b) != content {
+			t.Fatalf("Expected %s to hold %q. Received %q instead.", name, content, b)
+		}
+	}
+
+	if _, _, err = a.Get("missing"); !errors.Is(err, ErrEntryNotFound) {
+		t.Fatalf("Expected ErrEntryNotFound. Received %v instead.", err)
+	}
+
+	plain := filepath.Join(t.TempDir(), archive)
+	if err = Create(plain, src, src); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenIndexed(plain); !errors.Is(err, ErrNotIndexed) {
+		t.Fatalf("Expected ErrNotIndexed. Received %v instead.", err)
+	}
+
+	padded := filepath.Join(t.TempDir(), archive)
+	if err = CreateIndexed(padded, src, src, WithBlockingFactor(20), WithVerifyAfterWrite()); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(padded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size()%(20*blockSize) != 0 {
+		t.Fatalf("Expected the archive to be padded to a full record. Received %d bytes instead.", fi.Size())
+	}
+	p, err := OpenIndexed(padded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if names := p.Names(); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected index %v. Received %v instead.", expected, names)
+	}
+
+	compressed := filepath.Join(t.TempDir(), archive)
+	if err = CreateIndexed(compressed, src, src, WithGzip()); !errors.Is(err, ErrIndexCompressed) {
+		t.Fatalf("Expected ErrIndexCompressed. Received %v instead.", err)
+	}
+}
+
+// benchmarkIndexedArchive creates an indexed archive of 1000 files and returns
+// its path and the name of the last file.
+func benchmarkIndexedArchive(b *testing.B) (string, string) {
+	src := b.TempDir()
+	for i := 0; i < 1000; i++ {
+		if err := os.WriteFile(filepath.Join(src, fmt.Sprintf("file%04d", i)), []byte("content"), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	tarball := filepath.Join(b.TempDir(), archive)
+	if err := CreateIndexed(tarball, src, src); err != nil {
+		b.Fatal(err)
+	}
+
+	return tarball, "file0999"
+}
+
+func BenchmarkIndexedGet(b *testing.B) {
+	tarball, name := benchmarkIndexedArchive(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		a, err := OpenIndexed(tarball)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, _, err = a.Get(name); err != nil {
+			b.Fatal(err)
+		}
+		a.Close()
+	}
+}
+
+func BenchmarkScanGet(b *testing.B) {
+	tarball, name := benchmarkIndexedArchive(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ExtractPAXRecords(tarball, name); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package tarski
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// inspectEntries is the number of entries listed by Inspect at the start and
+// end of an archive.
+const inspectEntries = 10
+
+// Inspect writes a human-readable summary of archive to w. It holds the number
+// of entries, their total size, the compression and tar format, the oldest and
+// newest modification times and a listing of the first and last entries in the
+// style of tar tvf. Names are sanitized so the output is printable UTF-8.
+func Inspect(archive string, w io.Writer) error {
+	m, err := ReadMetadata(archive)
+	if err != nil {
+		return err
+	}
+
+	var first, last []*tar.Header
+	n := 0
+	err = walkHeaders(archive, func(h *tar.Header) bool {
+		if n < inspectEntries {
+			first = append(first, h)
+		} else {
+			last = append(last, h)
+			if len(last) > inspectEntries {
+				last = last[1:]
+			}
+		}
+		n++
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Entries:     %d\n", m.Entries)
+	fmt.Fprintf(&b, "Size:        %d bytes\n", m.Size)
+	fmt.Fprintf(&b, "Compression: %s\n", m.Compression)
+	fmt.Fprintf(&b, "Format:      %s\n", m.Format)
+	if m.Entries > 0 {
+		fmt.Fprintf(&b, "Oldest:      %s %s\n", m.OldestModTime.UTC().Format(time.RFC3339), printable(m.OldestEntry))
+		fmt.Fprintf(&b, "Newest:      %s %s\n", m.NewestModTime.UTC().Format(time.RFC3339), printable(m.NewestEntry))
+		b.WriteString("\n")
+	}
+
+	for _, h := range first {
+		b.WriteString(listEntry(h))
+	}
+	if skipped := n - len(first) - len(last); skipped > 0 {
+		fmt.Fprintf(&b, "... %d more entries\n", skipped)
+	}
+	for _, h := range last {
+		b.WriteString(listEntry(h))
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// InspectString returns the summary of archive written by Inspect.
+func InspectString(archive string) (string, error) {
+	var b strings.Builder
+	if err := Inspect(archive, &b); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// listEntry formats h like a line of tar tvf.
+func listEntry(h *tar.Header) string {
+	name := printable(h.Name)
+	switch h.Typeflag {
+	case tar.TypeSymlink:
+		name += " -> " + printable(h.Linkname)
+	case tar.TypeLink:
+		name += " link to " + printable(h.Linkname)
+	}
+
+	return fmt.Sprintf("%s %d/%d %10d %s %s\n", h.FileInfo().Mode(), h.Uid, h.Gid, h.Size, h.ModTime.UTC().Format("2006-01-02 15:04"), name)
+}
+
+// printable replaces invalid UTF-8 and non-printable characters in s.
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsPrint(r) {
+			return unicode.ReplacementChar
+		}
+		return r
+	}, strings.ToValidUTF8(s, string(unicode.ReplacementChar)))
+}
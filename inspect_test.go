@@ -0,0 +1,65 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestInspect(t *testing.T) {
+	old := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	recent := time.Date(2021, time.February, 3, 4, 5, 6, 0, time.UTC)
+
+	tes := []testEntry{
+		{&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755, ModTime: old}, ""},
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "dir/link", Linkname: "target", Mode: 0777, ModTime: recent}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/bad\nname\xff", Mode: 0644, ModTime: recent}, "x"},
+	}
+	for i := 0; i < 25; i++ {
+		tes = append(tes, testEntry{&tar.Header{Typeflag: tar.TypeReg, Name: fmt.Sprintf("dir/file%02d", i), Mode: 0644, ModTime: recent}, "content"})
+	}
+	tarball := writeTestArchive(t, tes)
+
+	var b bytes.Buffer
+	if err := Inspect(tarball, &b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	s, err := InspectString(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != out {
+		t.Fatalf("Expected InspectString to match Inspect.")
+	}
+
+	if !utf8.ValidString(out) {
+		t.Fatalf("Expected valid UTF-8. Received %q instead.", out)
+	}
+
+	for _, expected := range []string{
+		"Entries:     28\n",
+		"Size:        176 bytes\n",
+		"Compression: none\n",
+		"Format:      ustar\n",
+		"Oldest:      2001-02-03T04:05:06Z dir/\n",
+		"Newest:      2021-02-03T04:05:06Z dir/link\n",
+		"dir/link -> target\n",
+		"dir/bad�name�\n",
+		"... 8 more entries\n",
+		"-rw-r--r-- 0/0          7 2021-02-03 04:05 dir/file24\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("Expected the summary to contain %q. Received %q instead.", expected, out)
+		}
+	}
+
+	if strings.Contains(out, "dir/file10\n") {
+		t.Fatalf("Expected dir/file10 not to be listed. Received %q instead.", out)
+	}
+}
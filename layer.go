@@ -0,0 +1,103 @@
+package tarski
+
+import (
+	"archive/tar"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// whiteoutPrefix marks an entry that removes the file of the same name
+	// without the prefix from lower layers.
+	whiteoutPrefix = ".wh."
+	// whiteoutOpaque marks a directory whose contents from lower layers are
+	// removed.
+	whiteoutOpaque = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// ExtractArchiveLayer applies an OCI image layer to the root filesystem at
+// rootPath. Layers need to be applied in order. Compressed layers are
+// decompressed transparently.
+// Entries escaping rootPath, also through symbolic links extracted earlier, are
+// rejected and links pointing outside of it are handled according to the
+// UnsafeSymlinkPolicy. Whiteouts and opaque directory markers remove the
+// corresponding files of lower layers below rootPath, existing files are
+// replaced and hard links are recreated. Extended attributes that cannot be set
+// due to missing privileges or filesystem support are skipped. Directory modes
+// and modification times are restored once all entries have been extracted.
+func ExtractArchiveLayer(archive string, rootPath string, opts ...Option) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	o := newOptions(opts)
+	o.layer = true
+	o.Overwrite = OverwriteReplace
+
+	return extractStream(f, rootPath, nil, o)
+}
+
+// whiteout processes h if it is a whiteout entry. It reports whether h was a
+// whiteout entry.
+func (e *extractor) whiteout(h *tar.Header) (bool, error) {
+	dir, base := filepath.Split(filepath.Clean(h.Name))
+	if !strings.HasPrefix(base, whiteoutPrefix) {
+		return false, nil
+	}
+
+	if base == whiteoutOpaque {
+		return true, e.opaque(dir)
+	}
+
+	// Whiteouts name a single entry of their directory. Anything else
+	// would remove the directory itself or one of its parents.
+	name := strings.TrimPrefix(base, whiteoutPrefix)
+	if name == "" || name == "." || name == ".." {
+		return true, fmt.Errorf("%s: %w", h.Name, ErrUnsafePath)
+	}
+
+	target, err := sanitizePath(e.path, filepath.Join(dir, name))
+	if err != nil {
+		return true, err
+	}
+
+	return true, os.RemoveAll(target)
+}
+
+// opaque removes all entries of dir that do not stem from the current layer.
+func (e *extractor) opaque(dir string) error {
+	path, err := sanitizePath(e.path, dir)
+	if err != nil {
+		return err
+	}
+
+	// The children are removed through path so it must not be a link
+	// leading out of the root.
+	if path, err = resolveBeneath(filepath.Clean(e.path), path); err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+
+	children, err := os.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, c := range children {
+		name := filepath.Join(dir, c.Name())
+		if e.seen[name] {
+			continue
+		}
+		if err = os.RemoveAll(filepath.Join(path, c.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
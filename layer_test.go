@@ -0,0 +1,127 @@
+package tarski
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestExtractArchiveLayer(t *testing.T) {
+	lower := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "a/", Mode: 0755}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "a/x", Mode: 0644}, content: "x"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "a/y", Mode: 0644}, content: "y"},
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "b/", Mode: 0755}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "b/z", Mode: 0644}, content: "z"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "keep", Mode: 0644}, content: "lower"},
+	})
+	upper := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "a/.wh.x", Mode: 0644}},
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "b/", Mode: 0700}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "b/new", Mode: 0644}, content: "new"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "b/.wh..wh..opq", Mode: 0644}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "keep", Mode: 0644}, content: "upper"},
+		{header: &tar.Header{Typeflag: tar.TypeLink, Name: "keep_link", Linkname: "keep"}},
+	})
+
+	root := t.TempDir()
+	for _, layer := range []string{lower, upper} {
+		if err := ExtractArchiveLayer(layer, root); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"a/x", "b/z", "a/.wh.x", "b/.wh..wh..opq"} {
+		if _, err := os.Lstat(filepath.Join(root, name)); !os.IsNotExist(err) {
+			t.Fatalf("Expected %s to be removed.", name)
+		}
+	}
+
+	for name, content := range map[string]string{"a/y": "y", "b/new": "new", "keep": "upper", "keep_link": "upper"} {
+		b, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Fatalf("Expected %s to contain %q. Found %q instead.", name, content, string(b))
+		}
+	}
+
+	fi, err := os.Stat(filepath.Join(root, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0700 {
+		t.Fatalf("Expected directory b to have mode 0700. Found %o instead.", fi.Mode().Perm())
+	}
+
+	a, err := os.Stat(filepath.Join(root, "keep"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.Stat(filepath.Join(root, "keep_link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Sys().(*syscall.Stat_t).Ino != b.Sys().(*syscall.Stat_t).Ino {
+		t.Fatalf("Expected keep_link to be a hard link to keep.")
+	}
+
+	evil := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "../evil", Mode: 0644}, content: "evil"},
+	})
+	if err = ExtractArchiveLayer(evil, root); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("Expected ErrUnsafePath. Received %v instead.", err)
+	}
+	if _, err = os.Lstat(filepath.Join(filepath.Dir(root), "evil")); !os.IsNotExist(err) {
+		t.Fatalf("Expected entry escaping the root not to be extracted.")
+	}
+}
+
+func TestExtractArchiveLayerUnsafe(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	outside := filepath.Join(parent, "outside")
+	if err := os.MkdirAll(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "victim"), []byte("victim"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, layer := range [][]testEntry{
+		{{&tar.Header{Typeflag: tar.TypeReg, Name: ".wh..", Mode: 0644}, ""}},
+		{{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/.wh..", Mode: 0644}, ""}},
+		{{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/.wh.", Mode: 0644}, ""}},
+		{{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/.wh...", Mode: 0644}, ""}},
+		{
+			{&tar.Header{Typeflag: tar.TypeSymlink, Name: "evil", Linkname: outside}, ""},
+			{&tar.Header{Typeflag: tar.TypeReg, Name: "evil/pwned", Mode: 0644}, "pwned"},
+		},
+		{{&tar.Header{Typeflag: tar.TypeReg, Name: "evil/.wh.victim", Mode: 0644}, ""}},
+		{{&tar.Header{Typeflag: tar.TypeReg, Name: "evil/.wh..wh..opq", Mode: 0644}, ""}},
+	} {
+		tarball := writeTestArchive(t, layer)
+		err := ExtractArchiveLayer(tarball, root, WithUnsafeSymlinkPolicy(UnsafeSymlinkAllow))
+		if !errors.Is(err, ErrUnsafePath) {
+			t.Fatalf("Expected ErrUnsafePath for %s. Received %v instead.", layer[len(layer)-1].header.Name, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "dir")); err != nil {
+		t.Fatalf("Expected the root to be left alone: %v", err)
+	}
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "victim" {
+		t.Fatalf("Expected only victim outside of the root. Found %v instead.", entries)
+	}
+}
@@ -0,0 +1,43 @@
+package tarski
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+)
+
+// List returns the headers of all entries of archive in archive order without
+// extracting anything. Compressed archives are decompressed transparently.
+func List(archive string) ([]*tar.Header, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ListReader(f)
+}
+
+// ListReader returns the headers of all entries of the tar stream read from r
+// in stream order. The content of the entries is skipped. Compressed streams are
+// decompressed transparently.
+func ListReader(r io.Reader) (headers []*tar.Header, err error) {
+	d, _, err := decompressReader(r, &Options{})
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	tr := tar.NewReader(d)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return headers, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		headers = append(headers, h)
+	}
+}
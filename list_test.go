@@ -0,0 +1,54 @@
+package tarski
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := CreateGzip(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	headers, err := List(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, h := range headers {
+		names = append(names, h.Name)
+	}
+
+	var b bytes.Buffer
+	if err = CreateToWriter(&b, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := ListReader(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) != len(headers) {
+		t.Fatalf("Expected %d entries. Received %d instead.", len(expected), len(headers))
+	}
+	for i, h := range expected {
+		if h.Name != names[i] || h.Size != headers[i].Size || h.Typeflag != headers[i].Typeflag {
+			t.Fatalf("Expected entry %s of size %d. Received %s of size %d instead.", h.Name, h.Size, names[i], headers[i].Size)
+		}
+	}
+
+	if _, err = List(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(err) {
+		t.Fatalf("Expected a not exist error. Received %v instead.", err)
+	}
+
+	empty, err := ListReader(bytes.NewReader(make([]byte, 2*blockSize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty) != 0 {
+		t.Fatalf("Expected no entries. Received %d instead.", len(empty))
+	}
+}
@@ -0,0 +1,76 @@
+package tarski
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// paxManifest is the name of the global PAX header holding the manifest
+// written by CreatePAXWithAttributes and the PAX record storing it.
+const paxManifest = "tarski.manifest"
+
+// CreatePAXWithAttributes creates a tar archive whose first entry is a global
+// PAX header named tarski.manifest. It stores attrs encoded as JSON in a PAX
+// record of the same name. This can be used to ship e.g. OCI style manifests
+// with an archive. Extraction skips the header.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreatePAXWithAttributes(archive string, path string, prefix string, attrs map[string]interface{}, opts ...Option) (err error) {
+	manifest, err := json.Marshal(attrs)
+	if err != nil {
+		return
+	}
+
+	o := newOptions(opts)
+	return createArchiveWith(archive, nil, o, func(w *tar.Writer) error {
+		err := w.WriteHeader(&tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			Name:       paxManifest,
+			PAXRecords: map[string]string{paxManifest: string(manifest)},
+		})
+		if err != nil {
+			return err
+		}
+
+		return doCreate(w, path, prefix, o)
+	})
+}
+
+// ReadPAXAttributes returns the manifest stored by CreatePAXWithAttributes.
+// Numbers are decoded as float64. A nil map is returned if the first entry of
+// the archive is not a manifest.
+func ReadPAXAttributes(archive string) (map[string]interface{}, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, _, err := decompressReader(f, &Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	h, err := tar.NewReader(c).Next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, ok := h.PAXRecords[paxManifest]
+	if h.Typeflag != tar.TypeXGlobalHeader || !ok {
+		return nil, nil
+	}
+
+	var attrs map[string]interface{}
+	if err = json.Unmarshal([]byte(manifest), &attrs); err != nil {
+		return nil, err
+	}
+
+	return attrs, nil
+}
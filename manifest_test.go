@@ -0,0 +1,63 @@
+package tarski
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCreatePAXWithAttributes(t *testing.T) {
+	attrs := map[string]interface{}{
+		"schemaVersion": float64(2),
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"annotations":   map[string]interface{}{"org.opencontainers.image.title": "tarski"},
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := CreatePAXWithAttributes(tarball, prefix, prefix, attrs); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := ReadPAXAttributes(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(found, attrs) {
+		t.Fatalf("Expected attributes %v. Received %v instead.", attrs, found)
+	}
+
+	dest := t.TempDir()
+	if err = Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dest, paxManifest)); !os.IsNotExist(err) {
+		t.Fatal("Expected the manifest to not be extracted.")
+	}
+	if _, err = os.Stat(filepath.Join(dest, entries[6])); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := filepath.Join(t.TempDir(), archive)
+	if err = CreatePAXWithAttributes(compressed, prefix, prefix, attrs, WithGzip()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format := detectCompression(data); format != CompressionGzip {
+		t.Fatalf("Expected a gzip compressed archive. Received %v instead.", format)
+	}
+	if found, err = ReadPAXAttributes(compressed); err != nil || !reflect.DeepEqual(found, attrs) {
+		t.Fatalf("Expected attributes %v. Received %v (%v) instead.", attrs, found, err)
+	}
+
+	plain := filepath.Join(t.TempDir(), archive)
+	if err = Create(plain, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+	if found, err = ReadPAXAttributes(plain); err != nil || found != nil {
+		t.Fatalf("Expected no attributes. Received %v (%v) instead.", found, err)
+	}
+}
@@ -0,0 +1,219 @@
+package tarski
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// entryPos identifies an entry by the index of the source archive it is found
+// in and its index within that archive.
+type entryPos struct {
+	source int
+	entry  int
+}
+
+// before reports whether p comes before q in the merged stream.
+func (p entryPos) before(q entryPos) bool {
+	return p.source < q.source || p.source == q.source && p.entry < q.entry
+}
+
+// Merge combines the entries of the source archives into a single uncompressed
+// tar archive dst. Sources are copied in order and may be compressed. If
+// several sources contain an entry of the same name only the entry of the last
+// source is kept, at the position it has in the stream of that source. Hard
+// links whose target is kept from a later source are moved right behind it.
+// Global PAX headers are always kept. dst is only replaced once the merge
+// succeeded and must not be one of the sources.
+func Merge(dst string, sources ...string) error {
+	return mergeArchives(dst, sources, nil)
+}
+
+// MergeSHA256 merges archives like Merge and returns the SHA256-hash checksum
+// of the merged tar stream.
+func MergeSHA256(dst string, sources ...string) (checksum []byte, err error) {
+	b := sha256.New()
+	if err = mergeArchives(dst, sources, b); err != nil {
+		return
+	}
+
+	return b.Sum(nil), nil
+}
+
+// mergeArchives writes the merged entries of sources to dst. If h is not nil the
+// merged tar stream is written to it as well.
+func mergeArchives(dst string, sources []string, h io.Writer) (err error) {
+	for _, source := range sources {
+		if err = sameArchive(dst, source); err != nil {
+			return
+		}
+	}
+
+	// The first pass records where the surviving entry of each name is found.
+	last := make(map[string]entryPos)
+	for i, source := range sources {
+		n := 0
+		err = walkHeaders(source, func(hdr *tar.Header) bool {
+			if hdr.Typeflag != tar.TypeXGlobalHeader {
+				last[filepath.Clean(hdr.Name)] = entryPos{i, n}
+			}
+			n++
+			return true
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	unlock, err := lockArchive(dst)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	// The merged archive is written next to dst and only renamed once it is
+	// complete so a failed merge leaves dst alone.
+	f, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".tmp-")
+	if err != nil {
+		return
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	// CreateTemp creates the file with mode 0600.
+	if err = f.Chmod(0644); err != nil {
+		return
+	}
+
+	var d io.Writer = f
+	if h != nil {
+		d = io.MultiWriter(f, h)
+	}
+	w := tar.NewWriter(d)
+
+	// Hard links whose target is only written later are held back until
+	// it is.
+	pending := make(map[string][]*tar.Header)
+	for i, source := range sources {
+		if err = copyEntries(w, source, func(hdr *tar.Header, n int) bool {
+			pos := entryPos{i, n}
+			if hdr.Typeflag != tar.TypeXGlobalHeader && last[filepath.Clean(hdr.Name)] != pos {
+				return false
+			}
+
+			if hdr.Typeflag == tar.TypeLink {
+				target := filepath.Clean(hdr.Linkname)
+				if p, ok := last[target]; ok && pos.before(p) {
+					pending[target] = append(pending[target], hdr)
+					return false
+				}
+			}
+
+			return true
+		}, func(hdr *tar.Header) error {
+			return writeLinks(w, pending, filepath.Clean(hdr.Name))
+		}); err != nil {
+			return
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	if err = f.Close(); err != nil {
+		return
+	}
+
+	return os.Rename(f.Name(), dst)
+}
+
+// writeLinks writes the hard links held back for target and the links held
+// back for them in turn.
+func writeLinks(w *tar.Writer, pending map[string][]*tar.Header, target string) error {
+	links := pending[target]
+	delete(pending, target)
+
+	for _, link := range links {
+		if err := w.WriteHeader(link); err != nil {
+			return err
+		}
+		if err := writeLinks(w, pending, filepath.Clean(link.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sameArchive fails with ErrMergeDestination if dst refers to source.
+func sameArchive(dst string, source string) error {
+	a, err := os.Stat(dst)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	b, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	if os.SameFile(a, b) {
+		return fmt.Errorf("%s: %w", source, ErrMergeDestination)
+	}
+
+	return nil
+}
+
+// copyEntries copies the entries of a possibly compressed archive for which
+// keep returns true to w. keep is passed the header and the index of each entry.
+// written is called after each copied entry.
+func copyEntries(w *tar.Writer, archive string, keep func(h *tar.Header, n int) bool, written func(h *tar.Header) error) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, _, err := decompressReader(f, &Options{})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	t := tar.NewReader(r)
+	for n := 0; ; n++ {
+		h, err := t.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !keep(h, n) {
+			continue
+		}
+
+		if err = w.WriteHeader(h); err != nil {
+			return err
+		}
+		if _, err = io.Copy(w, t); err != nil {
+			return err
+		}
+
+		if err = written(h); err != nil {
+			return err
+		}
+	}
+}
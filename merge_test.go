@@ -0,0 +1,127 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	first := t.TempDir()
+	second := t.TempDir()
+	for dir, files := range map[string]map[string]string{
+		first:  {"a": "first a", "b": "first b"},
+		second: {"b": "second b", "c": "second c"},
+	} {
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	dir := t.TempDir()
+	one := filepath.Join(dir, "one.tar")
+	if err := Create(one, first, first); err != nil {
+		t.Fatal(err)
+	}
+	two := filepath.Join(dir, "two.tar.gz")
+	if err := CreateGzip(two, second, second); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := filepath.Join(dir, archive)
+	checksum, err := MergeSHA256(merged, one, two)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"a", "b", "c"}
+	names := readEntryNames(t, merged)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Found %v instead.", expected, names)
+	}
+
+	dest := t.TempDir()
+	extracted, err := ExtractSHA256(merged, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, extracted) {
+		t.Fatalf("Expected checksum %x. Received %x instead.", extracted, checksum)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dest, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "second b" {
+		t.Fatalf("Expected b to hold %q. Received %q instead.", "second b", content)
+	}
+}
+
+func TestMergeIntoSource(t *testing.T) {
+	dir := t.TempDir()
+	one := filepath.Join(dir, "one.tar")
+	if err := Create(one, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+	two := filepath.Join(dir, "two.tar")
+	if err := Create(two, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.ReadFile(one)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Merge(one, one, two); !errors.Is(err, ErrMergeDestination) {
+		t.Fatalf("Expected ErrMergeDestination. Received %v instead.", err)
+	}
+
+	after, err := os.ReadFile(one)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatalf("Expected the source archive to be left alone.")
+	}
+}
+
+func TestMergeHardLinkToLaterSource(t *testing.T) {
+	first := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "target", Mode: 0644, Size: 5}, "first"},
+		{&tar.Header{Typeflag: tar.TypeLink, Name: "link", Linkname: "target"}, ""},
+	})
+	second := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "target", Mode: 0644, Size: 6}, "second"},
+	})
+
+	merged := filepath.Join(t.TempDir(), archive)
+	if err := Merge(merged, first, second); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"target", "link"}
+	names := readEntryNames(t, merged)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Found %v instead.", expected, names)
+	}
+
+	dest := t.TempDir()
+	if err := Extract(merged, dest); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dest, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "second" {
+		t.Fatalf("Expected link to hold %q. Received %q instead.", "second", content)
+	}
+}
@@ -0,0 +1,109 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bufio"
+	"io"
+	"os"
+	"time"
+)
+
+// ArchiveMetadata summarizes the entries of an archive.
+type ArchiveMetadata struct {
+	Compression CompressionFormat
+	Format      TarFormat
+
+	// Entries is the number of entries and Size the sum of their sizes.
+	Entries int
+	Size    int64
+
+	// OldestEntry and NewestEntry are the names of the entries with the
+	// oldest and newest modification time.
+	OldestEntry   string
+	OldestModTime time.Time
+	NewestEntry   string
+	NewestModTime time.Time
+
+	// LargestEntry is the name of the largest entry.
+	LargestEntry string
+	LargestSize  int64
+}
+
+// ReadMetadata collects the ArchiveMetadata of archive in a single pass over
+// its headers. The content of entries is skipped. Gzip, bzip2, zstd and xz
+// compressed archives are decompressed transparently.
+func ReadMetadata(archive string) (*ArchiveMetadata, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := &ArchiveMetadata{}
+	r, c, err := decompressReader(f, &Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	m.Compression = c
+
+	b := bufio.NewReader(r)
+	if block, err := b.Peek(blockSize); err == nil {
+		m.Format = detectTarFormat(block)
+	}
+
+	tr := tar.NewReader(b)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if m.Entries == 0 || h.ModTime.Before(m.OldestModTime) {
+			m.OldestEntry, m.OldestModTime = h.Name, h.ModTime
+		}
+		if m.Entries == 0 || h.ModTime.After(m.NewestModTime) {
+			m.NewestEntry, m.NewestModTime = h.Name, h.ModTime
+		}
+		if m.Entries == 0 || h.Size > m.LargestSize {
+			m.LargestEntry, m.LargestSize = h.Name, h.Size
+		}
+
+		m.Entries++
+		m.Size += h.Size
+	}
+
+	return m, nil
+}
+
+// CountEntries returns the number of entries of archive. Only the headers are
+// parsed and none are retained so this is cheaper than collecting them for
+// archives with many entries. Compressed archives are decompressed
+// transparently.
+func CountEntries(archive string) (n int, err error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	r, _, err := decompressReader(f, &Options{})
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	for {
+		if _, err = tr.Next(); err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+}
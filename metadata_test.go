@@ -0,0 +1,151 @@
+package tarski
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadMetadata(t *testing.T) {
+	old := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	mid := time.Date(2011, time.February, 3, 4, 5, 6, 0, time.UTC)
+	recent := time.Date(2021, time.February, 3, 4, 5, 6, 0, time.UTC)
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755, ModTime: mid}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/small", Mode: 0644, ModTime: recent}, "small"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/large", Mode: 0644, ModTime: old}, "larger content"},
+	})
+
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzipped := filepath.Join(t.TempDir(), archive+".gz")
+	f, err := os.Create(gzipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	z := gzip.NewWriter(f)
+	if _, err = z.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, compression := range map[string]CompressionFormat{tarball: CompressionNone, gzipped: CompressionGzip} {
+		m, err := ReadMetadata(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := ArchiveMetadata{
+			Compression:   compression,
+			Format:        TarFormatUSTAR,
+			Entries:       3,
+			Size:          int64(len("small") + len("larger content")),
+			OldestEntry:   "dir/large",
+			OldestModTime: old,
+			NewestEntry:   "dir/small",
+			NewestModTime: recent,
+			LargestEntry:  "dir/large",
+			LargestSize:   int64(len("larger content")),
+		}
+		m.OldestModTime = m.OldestModTime.UTC()
+		m.NewestModTime = m.NewestModTime.UTC()
+		if *m != expected {
+			t.Fatalf("Expected metadata %+v. Received %+v instead.", expected, *m)
+		}
+	}
+}
+
+func TestReadMetadataTestdata(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadMetadata(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := readEntryNames(t, tarball)
+	if m.Entries != len(names) {
+		t.Fatalf("Expected %d entries. Received %d instead.", len(names), m.Entries)
+	}
+	if m.Format == TarFormatUnknown {
+		t.Fatal("Expected the tar format to be detected.")
+	}
+}
+
+func TestCountEntries(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := CountEntries(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := readEntryNames(t, tarball); n != len(names) {
+		t.Fatalf("Expected %d entries. Received %d instead.", len(names), n)
+	}
+}
+
+// benchmarkCountArchive writes an archive of 10000 empty files.
+func benchmarkCountArchive(b *testing.B) string {
+	tarball := filepath.Join(b.TempDir(), archive)
+	f, err := os.Create(tarball)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	w := tar.NewWriter(f)
+	for i := 0; i < 10000; i++ {
+		if err = w.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: fmt.Sprintf("file%05d", i), Mode: 0644}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	return tarball
+}
+
+func BenchmarkCountEntries(b *testing.B) {
+	tarball := benchmarkCountArchive(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		n, err := CountEntries(tarball)
+		if err != nil || n != 10000 {
+			b.Fatalf("Expected 10000 entries. Received %d, %v instead.", n, err)
+		}
+	}
+}
+
+func BenchmarkCountHeaders(b *testing.B) {
+	tarball := benchmarkCountArchive(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		headers, err := readHeaders(tarball)
+		if err != nil || len(headers) != 10000 {
+			b.Fatalf("Expected 10000 entries. Received %d, %v instead.", len(headers), err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package tarski
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+)
+
+// ReExtractModified extracts the regular files of an archive whose
+// modification time is newer than that of their counterpart under destPath.
+// Files missing under destPath are extracted as well and all of them are
+// extracted like Extract does, including their extended attributes. All other
+// entries are ignored. The names of the extracted entries are returned. Content is not
+// compared so this is only as reliable as the recorded modification times.
+func ReExtractModified(archive string, destPath string) (extracted []string, err error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	o := &Options{Overwrite: OverwriteReplace}
+	c, _, err := decompressReader(f, o)
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	e := newExtractor(destPath, o)
+	r := tar.NewReader(c)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if h.Typeflag == tar.TypeXGlobalHeader {
+			if err = e.extract(h, r); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		entry, err := sanitizePath(destPath, h.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		fi, err := os.Lstat(entry)
+		if err == nil && !h.ModTime.After(fi.ModTime()) {
+			continue
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		name := h.Name
+		if err = e.extract(h, r); err != nil {
+			return nil, err
+		}
+		extracted = append(extracted, name)
+	}
+
+	return
+}
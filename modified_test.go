@@ -0,0 +1,96 @@
+package tarski
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReExtractModified(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755, ModTime: now}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/newer", Mode: 0644, ModTime: now}, "new"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/older", Mode: 0644, ModTime: now.Add(-time.Hour)}, "new"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/missing", Mode: 0644, ModTime: now}, "new"},
+	})
+
+	dest := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dest, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dir/newer", "dir/older"} {
+		p := filepath.Join(dest, name)
+		if err := os.WriteFile(p, []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, now.Add(-time.Minute), now.Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	extracted, err := ReExtractModified(tarball, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"dir/newer", "dir/missing"}
+	if !reflect.DeepEqual(extracted, expected) {
+		t.Fatalf("Expected %v to be extracted. Received %v instead.", expected, extracted)
+	}
+
+	for name, content := range map[string]string{"dir/newer": "new", "dir/older": "old", "dir/missing": "new"} {
+		b, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Fatalf("Expected %s to hold %q. Received %q instead.", name, content, b)
+		}
+	}
+
+	fi, err := os.Stat(filepath.Join(dest, "dir/newer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.ModTime().Equal(now) {
+		t.Fatalf("Expected modification time %v. Received %v instead.", now, fi.ModTime())
+	}
+
+	if extracted, err = ReExtractModified(tarball, dest); err != nil || len(extracted) != 0 {
+		t.Fatalf("Expected nothing to be extracted again. Received %v, %v instead.", extracted, err)
+	}
+}
+
+func TestReExtractModifiedXattrs(t *testing.T) {
+	src := t.TempDir()
+	file := filepath.Join(src, "file")
+	if err := os.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetXattr(file, "user.test", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	if _, err := ReExtractModified(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := GetXattr(filepath.Join(dest, "file"), "user.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "value" {
+		t.Fatalf("Expected extended attribute user.test to be %q. Received %q instead.", "value", value)
+	}
+}
@@ -0,0 +1,62 @@
+//go:build linux
+
+package tarski
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+)
+
+// CreateFromMount creates a tar archive of the filesystem mounted at
+// mountPath, e.g. the merged view of an overlay mount. mountPath is bind
+// mounted read-only onto a private temporary directory which is archived and
+// unmounted afterwards so the contents cannot change through this view while
+// the archive is created. Mounts below mountPath are not included.
+// The string given by prefix will be stripped from all entries as with Create
+// and has to be mountPath or a directory below it. An empty prefix is taken to
+// be mountPath.
+//
+// This requires CAP_SYS_ADMIN. The temporary mount is visible to every
+// process in the caller's mount namespace for the duration of the call and
+// exposes the contents of mountPath to anyone allowed to access the temporary
+// directory, which is created with mode 0700.
+func CreateFromMount(archive string, mountPath string, prefix string, opts ...Option) (err error) {
+	mountPath = filepath.Clean(mountPath)
+	if prefix == "" {
+		prefix = mountPath
+	}
+	prefix = filepath.Clean(prefix)
+	if !isBeneath(mountPath, prefix) {
+		return errors.New("Prefix must be located under the mount path.")
+	}
+
+	rel, err := filepath.Rel(mountPath, prefix)
+	if err != nil {
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "tarski-mount-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(dir)
+
+	if err = unix.Mount(mountPath, dir, "", unix.MS_BIND, ""); err != nil {
+		return
+	}
+	defer func() {
+		if uerr := unix.Unmount(dir, 0); err == nil {
+			err = uerr
+		}
+	}()
+
+	// MS_RDONLY is ignored when creating a bind mount and needs to be set
+	// through a remount.
+	if err = unix.Mount("", dir, "", unix.MS_REMOUNT|unix.MS_BIND|unix.MS_RDONLY, ""); err != nil {
+		return
+	}
+
+	return Create(archive, dir, filepath.Join(dir, rel), opts...)
+}
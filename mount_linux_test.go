@@ -0,0 +1,56 @@
+//go:build linux
+
+package tarski
+
+import (
+	"errors"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCreateFromMount(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Creating mounts requires root.")
+	}
+
+	src := t.TempDir()
+	if err := os.Mkdir(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "dir", "file"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	err := CreateFromMount(tarball, src, src)
+	if errors.Is(err, unix.EPERM) {
+		t.Skip("Creating mounts is not permitted.")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := readEntryNames(t, tarball)
+	expected := []string{"dir/", "dir/file"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Received %v instead.", expected, names)
+	}
+
+	if err = CreateFromMount(tarball, src, "/elsewhere"); err == nil {
+		t.Fatal("Expected a prefix outside of the mount path to be rejected.")
+	}
+	if err = CreateFromMount(tarball, src, src+"x"); err == nil {
+		t.Fatal("Expected a sibling sharing the mount path as a prefix to be rejected.")
+	}
+
+	if err = CreateFromMount(tarball, src+"/", ""); err != nil {
+		t.Fatal(err)
+	}
+	names = readEntryNames(t, tarball)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v with an empty prefix. Received %v instead.", expected, names)
+	}
+}
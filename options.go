@@ -0,0 +1,722 @@
+package tarski
+
+import (
+	"archive/tar"
+	"context"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// OverwritePolicy controls how extraction deals with files that already exist
+// at the location of an entry.
+type OverwritePolicy int
+
+const (
+	// OverwriteError fails extraction of entries that already exist.
+	OverwriteError OverwritePolicy = iota
+	// OverwriteReplace removes existing files before extracting an entry.
+	// Existing directories are kept if the entry is a directory as well.
+	OverwriteReplace
+)
+
+// OversizePolicy controls how extended attribute values exceeding the limit set
+// with WithXattrSizeLimit are dealt with.
+type OversizePolicy int
+
+const (
+	// OversizeError fails with ErrXattrTooLarge.
+	OversizeError OversizePolicy = iota
+	// OversizeTruncate cuts the value down to the limit.
+	OversizeTruncate
+	// OversizeSkip leaves the attribute unset.
+	OversizeSkip
+)
+
+// AbsoluteSymlinkPolicy controls how extraction deals with symbolic links
+// pointing to absolute paths.
+type AbsoluteSymlinkPolicy int
+
+const (
+	// AbsoluteSymlinkAllow leaves absolute symbolic links to the
+	// UnsafeSymlinkPolicy.
+	AbsoluteSymlinkAllow AbsoluteSymlinkPolicy = iota
+	// AbsoluteSymlinkError fails with ErrAbsoluteSymlink.
+	AbsoluteSymlinkError
+	// AbsoluteSymlinkSkip leaves absolute symbolic links out.
+	AbsoluteSymlinkSkip
+	// AbsoluteSymlinkRelativize rewrites the target to point to the same
+	// path below the extraction root using a relative target.
+	AbsoluteSymlinkRelativize
+)
+
+// UnsafeSymlinkPolicy controls how extraction deals with symbolic links whose
+// target lies outside of the extraction root, either because it is an absolute
+// path elsewhere or because it leaves the root through ".." components. The
+// target is resolved from the directory the link is created in after earlier
+// links have been followed.
+type UnsafeSymlinkPolicy int
+
+const (
+	// UnsafeSymlinkRewrite rewrites the target to a relative target below
+	// the extraction root as if the root was the root directory. This is
+	// the default.
+	UnsafeSymlinkRewrite UnsafeSymlinkPolicy = iota
+	// UnsafeSymlinkError fails with ErrUnsafeSymlink.
+	UnsafeSymlinkError
+	// UnsafeSymlinkAllow creates such symbolic links as they are. Entries
+	// are never written through them but the links themselves may be
+	// followed by whoever uses the extracted tree.
+	UnsafeSymlinkAllow
+)
+
+// Option configures the behaviour of the create and extract functions.
+type Option func(*Options)
+
+// Options holds the configuration assembled from a list of Option values.
+type Options struct {
+	// ContinueOnError keeps extracting the remaining entries when extracting
+	// a single entry fails. All errors are reported once extraction has
+	// finished.
+	ContinueOnError bool
+
+	// SkipVolumeHeaders ignores GNU tar volume headers instead of failing
+	// with ErrMultiVolumeArchive.
+	SkipVolumeHeaders bool
+
+	// RollbackOnMismatch removes the extracted directory when its checksum
+	// does not match the expected one.
+	RollbackOnMismatch bool
+
+	// Overwrite controls how existing files are dealt with.
+	Overwrite OverwritePolicy
+
+	// ContinueOnXattrErrors keeps setting the remaining extended attributes
+	// when setting a single one fails.
+	ContinueOnXattrErrors bool
+
+	// CleanXattrsOnOverwrite removes the extended attributes of existing
+	// files before the attributes of the archive entry are restored.
+	CleanXattrsOnOverwrite bool
+
+	// ExcludeHidden skips dot-files and dot-directories during archive
+	// creation.
+	ExcludeHidden bool
+
+	// ExcludeGlob skips files and directories during archive creation whose
+	// entry name or base name matches one of the filepath.Match patterns.
+	ExcludeGlob []string
+
+	// StoreBirthTime stores the birth time of each entry in the
+	// SCHILY.crtime PAX record.
+	StoreBirthTime bool
+
+	// ACLPAXEncoding stores POSIX ACLs in the SCHILY.acl PAX records in
+	// text form instead of as binary extended attributes.
+	ACLPAXEncoding bool
+
+	// AbsoluteSymlinks controls how absolute symbolic links are extracted.
+	AbsoluteSymlinks AbsoluteSymlinkPolicy
+
+	// UnsafeSymlinks controls how symbolic links pointing outside of the
+	// extraction root are extracted. It applies after AbsoluteSymlinks.
+	UnsafeSymlinks UnsafeSymlinkPolicy
+
+	// PAXDeviceRecords stores device numbers in the SCHILY.dev PAX records
+	// during creation and prefers them during extraction.
+	PAXDeviceRecords bool
+
+	// PathTransform is applied to the name of every entry during archive
+	// creation.
+	PathTransform func(name string) (string, error)
+
+	// PAXRecords are added to the header of every entry during archive
+	// creation.
+	PAXRecords map[string]string
+
+	// MaxPathDepth limits the number of components of extracted entry
+	// names. Zero uses defaultMaxPathDepth and negative values disable the
+	// limit.
+	MaxPathDepth int
+
+	// GlobalPAXRecords are written to a global PAX header preceding all
+	// entries during archive creation.
+	GlobalPAXRecords map[string]string
+
+	// RetryPredicate selects the errors extraction of an entry is retried
+	// for up to RetryMax times. The first retry waits RetryBackoff and every
+	// following one twice as long as the previous one.
+	RetryPredicate func(error) bool
+	RetryMax       int
+	RetryBackoff   time.Duration
+
+	// ChmodUmask is cleared from the mode of every extracted file and
+	// directory.
+	ChmodUmask os.FileMode
+
+	// MaxPermissions limits the permission bits of every extracted file and
+	// directory to those set in it. Zero disables the limit.
+	MaxPermissions os.FileMode
+
+	// SkipUnsupportedXattr ignores extended attributes on filesystems that
+	// do not support them instead of failing.
+	SkipUnsupportedXattr bool
+
+	// XattrSizeLimit is the maximum size of an extended attribute value that
+	// is set. Zero disables the limit.
+	XattrSizeLimit int
+
+	// XattrOversize controls how values exceeding XattrSizeLimit are dealt
+	// with.
+	XattrOversize OversizePolicy
+
+	// BlockingFactor pads created archives to a multiple of BlockingFactor
+	// blocks of 512 bytes. Zero disables padding.
+	BlockingFactor int
+
+	// RecordSize pads created archives to a multiple of RecordSize bytes. It
+	// has to be a multiple of 512 and takes precedence over BlockingFactor.
+	RecordSize int
+
+	// XattrEncoding selects how extended attribute values are stored in
+	// created archives.
+	XattrEncoding XattrEncoding
+
+	// SkipPathValidation writes entry names without checking them with
+	// IsValidTarPath.
+	SkipPathValidation bool
+
+	// Format forces the tar format of the headers written during archive
+	// creation. tar.FormatUnknown lets archive/tar pick the most compatible
+	// format able to represent each header.
+	Format tar.Format
+
+	// Compression selects the compression applied during archive creation.
+	// Extraction detects the compression of an archive by itself and only
+	// verifies that it matches if Compression is set.
+	Compression CompressionFormat
+
+	// Zstd tunes the encoder used for zstd compression.
+	Zstd ZstdOptions
+
+	// XZ tunes the encoder used for xz compression.
+	XZ XZOptions
+
+	// CreateDestinationMode is the mode the destination directory is created
+	// with if it does not exist. Zero leaves its creation to extraction.
+	CreateDestinationMode os.FileMode
+
+	// FollowSymlinks archives the targets of symbolic links instead of the
+	// links themselves.
+	FollowSymlinks bool
+
+	// HashAlgorithm is fed the uncompressed tar stream by CreateWithOptions
+	// and ExtractWithOptions if it is not nil. Its Sum is the same checksum
+	// CreateWithHash and ExtractWithHash return.
+	HashAlgorithm hash.Hash
+
+	// UIDMap translates the owner of every entry during archive creation
+	// and extraction if it is not nil.
+	UIDMap func(int) int
+
+	// GIDMap translates the group of every entry during archive creation
+	// and extraction if it is not nil.
+	GIDMap func(int) int
+
+	// StripComponents removes that many leading components from the name of
+	// every entry during extraction. Entries with no components left are
+	// skipped.
+	StripComponents int
+
+	// Progress is called to report the progress of create and extract
+	// operations.
+	Progress ProgressFunc
+
+	// XattrProgress is called with the number of extended attributes found
+	// on each file during archive creation.
+	XattrProgress func(path string, xattrCount int)
+
+	// VerifyEntryHash compares the content of extracted regular files with
+	// the hash stored in their tarski.sha256 PAX record.
+	VerifyEntryHash bool
+
+	// VerifyAfterWrite reads back the content of every regular file after it
+	// has been written to the archive and compares it to the original.
+	VerifyAfterWrite bool
+
+	// verify is the archive being created with VerifyAfterWrite.
+	verify io.ReadWriteSeeker
+
+	// totalEntries is reported as TotalEntries during extraction if it is
+	// not zero.
+	totalEntries int
+
+	// ctx cancels create and extract operations if it is not nil.
+	ctx context.Context
+
+	// sync flushes extracted files to disk before they are closed.
+	sync bool
+
+	// layer enables the OCI layer semantics used by ExtractArchiveLayer.
+	layer bool
+
+	// filesOnly restricts extraction to regular files. It is set by
+	// ExtractFilesOnly.
+	filesOnly bool
+}
+
+// fileMode applies the configured permission restrictions to the mode of an
+// extracted file or directory.
+func (o *Options) fileMode(mode os.FileMode) os.FileMode {
+	if o.MaxPermissions != 0 {
+		mode &= o.MaxPermissions | os.ModeType
+	}
+
+	return mode &^ o.ChmodUmask
+}
+
+// defaultMaxPathDepth is the default limit on the number of components of
+// extracted entry names. It is twice MAXSYMLINKS on Linux.
+const defaultMaxPathDepth = 100
+
+// mapIDs translates the owner and group of h with UIDMap and GIDMap.
+func (o *Options) mapIDs(h *tar.Header) {
+	if o.UIDMap != nil {
+		h.Uid = o.UIDMap(h.Uid)
+	}
+	if o.GIDMap != nil {
+		h.Gid = o.GIDMap(h.Gid)
+	}
+}
+
+// maxPathDepth returns the effective limit on the number of components of
+// extracted entry names. It is zero if there is no limit.
+func (o *Options) maxPathDepth() int {
+	switch {
+	case o.MaxPathDepth == 0:
+		return defaultMaxPathDepth
+	case o.MaxPathDepth < 0:
+		return 0
+	}
+
+	return o.MaxPathDepth
+}
+
+// excluded reports whether the entry name or its base name matches one of the
+// ExcludeGlob patterns. Malformed patterns return filepath.ErrBadPattern.
+func (o *Options) excluded(name string) (bool, error) {
+	name = strings.TrimSuffix(name, "/")
+	for _, pattern := range o.ExcludeGlob {
+		for _, s := range []string{name, filepath.Base(name)} {
+			if ok, err := filepath.Match(pattern, s); ok || err != nil {
+				return ok, err
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// err returns the error of the context of a create or extract operation.
+func (o *Options) err() error {
+	if o.ctx == nil {
+		return nil
+	}
+
+	return o.ctx.Err()
+}
+
+func newOptions(opts []Option) *Options {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// WithContinueOnError makes extraction carry on past entries that could not
+// be extracted. The collected errors are returned as a MultiError.
+func WithContinueOnError() Option {
+	return func(o *Options) {
+		o.ContinueOnError = true
+	}
+}
+
+// WithSkipVolumeHeaders makes extraction ignore GNU tar volume headers. This is
+// only safe for single-volume archives carrying a volume label or for
+// multi-volume archives that have already been reassembled.
+func WithSkipVolumeHeaders() Option {
+	return func(o *Options) {
+		o.SkipVolumeHeaders = true
+	}
+}
+
+// WithRollbackOnMismatch makes ExtractSHA256Verify remove the extracted
+// directory when the checksum of the archive does not match.
+func WithRollbackOnMismatch() Option {
+	return func(o *Options) {
+		o.RollbackOnMismatch = true
+	}
+}
+
+// WithOverwrite sets the policy for entries that already exist during
+// extraction.
+func WithOverwrite(policy OverwritePolicy) Option {
+	return func(o *Options) {
+		o.Overwrite = policy
+	}
+}
+
+// WithStoreBirthTime makes archive creation store the birth time of each entry
+// in the SCHILY.crtime PAX record on filesystems that report it.
+// Linux offers no interface to set the birth time of a file so it is not
+// restored during extraction.
+func WithStoreBirthTime() Option {
+	return func(o *Options) {
+		o.StoreBirthTime = true
+	}
+}
+
+// WithACLPAXEncoding makes archive creation store POSIX ACLs in the
+// SCHILY.acl.access and SCHILY.acl.default PAX records in text form as GNU tar
+// and star do. Extraction always restores ACLs found in these records.
+func WithACLPAXEncoding() Option {
+	return func(o *Options) {
+		o.ACLPAXEncoding = true
+	}
+}
+
+// WithRetryOnError makes extraction retry entries that failed with an error
+// predicate returns true for, e.g. transient EIO or EAGAIN errors on network
+// filesystems. Each entry is retried up to maxRetries times with an exponential
+// backoff starting at backoff. Regular files can only be retried if the failure
+// happened before their content was read from the archive.
+func WithRetryOnError(predicate func(error) bool, maxRetries int, backoff time.Duration) Option {
+	return func(o *Options) {
+		o.RetryPredicate = predicate
+		o.RetryMax = maxRetries
+		o.RetryBackoff = backoff
+	}
+}
+
+// WithAbsoluteSymlinkPolicy sets how extraction deals with symbolic links
+// pointing to absolute paths. By default they are only subject to the
+// UnsafeSymlinkPolicy.
+func WithAbsoluteSymlinkPolicy(policy AbsoluteSymlinkPolicy) Option {
+	return func(o *Options) {
+		o.AbsoluteSymlinks = policy
+	}
+}
+
+// WithUnsafeSymlinkPolicy sets how extraction deals with symbolic links whose
+// target lies outside of the extraction root. By default their targets are
+// rewritten to stay below the root.
+func WithUnsafeSymlinkPolicy(policy UnsafeSymlinkPolicy) Option {
+	return func(o *Options) {
+		o.UnsafeSymlinks = policy
+	}
+}
+
+// WithPAXDeviceRecords makes archive creation store the device numbers of
+// character and block devices as decimal strings in the SCHILY.dev.major and
+// SCHILY.dev.minor PAX records in addition to the header fields. Extraction
+// prefers these records over the header fields.
+func WithPAXDeviceRecords() Option {
+	return func(o *Options) {
+		o.PAXDeviceRecords = true
+	}
+}
+
+// WithIDMap makes archive creation and extraction translate the owner of every
+// entry with uidMap and its group with gidMap. Either can be nil to keep the
+// respective ids.
+func WithIDMap(uidMap func(int) int, gidMap func(int) int) Option {
+	return func(o *Options) {
+		o.UIDMap = uidMap
+		o.GIDMap = gidMap
+	}
+}
+
+// WithStripComponents makes extraction remove the first n components from the
+// name of every entry and hard link target as tar --strip-components does.
+// Entries with no components left are skipped.
+func WithStripComponents(n int) Option {
+	return func(o *Options) {
+		o.StripComponents = n
+	}
+}
+
+// WithPathTransform makes archive creation pass the name of every entry through
+// fn after the prefix has been stripped. Archive creation fails if fn returns
+// an error unless WithContinueOnError() is passed in which case the entry is
+// skipped.
+func WithPathTransform(fn func(name string) (string, error)) Option {
+	return func(o *Options) {
+		o.PathTransform = fn
+	}
+}
+
+// WithPAXRecord makes archive creation add the PAX record key with value to the
+// header of every entry. It can be given multiple times to add several records.
+// Records written by tarski itself take precedence.
+func WithPAXRecord(key string, value string) Option {
+	return func(o *Options) {
+		if o.PAXRecords == nil {
+			o.PAXRecords = make(map[string]string)
+		}
+		o.PAXRecords[key] = value
+	}
+}
+
+// WithGlobalPAXRecord makes archive creation write the PAX record key with value
+// to a global PAX header preceding all entries, e.g. to record the host or tool
+// that created the archive. It can be given multiple times to add several
+// records.
+func WithGlobalPAXRecord(key string, value string) Option {
+	return func(o *Options) {
+		if o.GlobalPAXRecords == nil {
+			o.GlobalPAXRecords = make(map[string]string)
+		}
+		o.GlobalPAXRecords[key] = value
+	}
+}
+
+// WithContinueOnXattrErrors makes SetAllXattr and SetAllXattrFd attempt all
+// extended attributes. The collected errors are returned as a MultiError.
+func WithContinueOnXattrErrors() Option {
+	return func(o *Options) {
+		o.ContinueOnXattrErrors = true
+	}
+}
+
+// WithCleanXattrsOnOverwrite makes extraction with OverwriteReplace remove all
+// extended attributes of a file before restoring those of the archive entry.
+// This matters for existing directories which are not replaced so their
+// extended attributes exactly match the archive afterwards.
+func WithCleanXattrsOnOverwrite() Option {
+	return func(o *Options) {
+		o.CleanXattrsOnOverwrite = true
+	}
+}
+
+// WithExcludeGlob makes archive creation skip files and directories matching
+// any of patterns. Patterns use the syntax of filepath.Match and are matched
+// against the entry name as well as its base name so ".git" excludes git
+// directories at any depth. The contents of excluded directories are skipped as
+// well.
+func WithExcludeGlob(patterns ...string) Option {
+	return func(o *Options) {
+		o.ExcludeGlob = append(o.ExcludeGlob, patterns...)
+	}
+}
+
+// WithExcludeHidden makes archive creation skip files and directories whose
+// name starts with a dot. The contents of hidden directories are skipped as
+// well.
+func WithExcludeHidden() Option {
+	return func(o *Options) {
+		o.ExcludeHidden = true
+	}
+}
+
+// WithProgress makes create and extract operations report their progress to
+// fn.
+func WithProgress(fn ProgressFunc) Option {
+	return func(o *Options) {
+		o.Progress = fn
+	}
+}
+
+// WithChmodUmask clears the bits set in umask from the mode of every extracted
+// file and directory. Unlike changing the process umask this only affects a
+// single operation and is safe to use from multiple goroutines. By default the
+// exact mode from the archive is restored.
+func WithChmodUmask(umask os.FileMode) Option {
+	return func(o *Options) {
+		o.ChmodUmask = umask
+	}
+}
+
+// WithFollowSymlinks makes archive creation store the target of each symbolic
+// link under the name of the link. Links to directories are descended into.
+// Creation fails with ErrSymlinkLoop if links nest too deeply which usually
+// means they form a cycle.
+func WithFollowSymlinks() Option {
+	return func(o *Options) {
+		o.FollowSymlinks = true
+	}
+}
+
+// WithMaxPermissions limits the permissions of every extracted file and
+// directory to mask. Setuid, setgid and sticky bits are cleared unless mask
+// contains os.ModeSetuid, os.ModeSetgid or os.ModeSticky. For example
+// WithMaxPermissions(0755) prevents group and world writable files.
+func WithMaxPermissions(mask os.FileMode) Option {
+	return func(o *Options) {
+		o.MaxPermissions = mask
+	}
+}
+
+// WithXattrProgress makes archive creation call fn for every file after its
+// extended attributes have been retrieved. fn receives the path of the file
+// and the number of extended attributes found which helps to track down slow
+// archive creation on files with many attributes.
+func WithXattrProgress(fn func(path string, xattrCount int)) Option {
+	return func(o *Options) {
+		o.XattrProgress = fn
+	}
+}
+
+// WithGzip makes archive creation compress the archive with gzip. During
+// extraction gzip compressed archives are detected automatically and WithGzip
+// only rejects archives that are not gzip compressed.
+func WithGzip() Option {
+	return func(o *Options) {
+		o.Compression = CompressionGzip
+	}
+}
+
+// WithZstdOptions tunes the zstd encoder used by CreateZstd and friends. It has
+// no effect on other compression formats.
+func WithZstdOptions(z ZstdOptions) Option {
+	return func(o *Options) {
+		o.Zstd = z
+	}
+}
+
+// WithXZOptions tunes the xz encoder used by CreateXZ and friends. It has no
+// effect on other compression formats.
+func WithXZOptions(x XZOptions) Option {
+	return func(o *Options) {
+		o.XZ = x
+	}
+}
+
+// WithFormat forces the tar format of created headers to one of
+// tar.FormatUSTAR, tar.FormatPAX or tar.FormatGNU. Writing a header fails if it
+// cannot be represented in the chosen format.
+func WithFormat(f tar.Format) Option {
+	return func(o *Options) {
+		o.Format = f
+	}
+}
+
+// WithBzip2 makes archive creation compress the archive with bzip2. During
+// extraction bzip2 compressed archives are detected automatically and
+// WithBzip2 only rejects archives that are not bzip2 compressed.
+func WithBzip2() Option {
+	return func(o *Options) {
+		o.Compression = CompressionBzip2
+	}
+}
+
+// WithXattrSizeLimit limits the size of extended attribute values to maxBytes
+// when setting them. Values exceeding the limit are dealt with according to
+// WithXattrOversizePolicy. During archive creation a warning is logged for
+// each value exceeding the limit but the value is stored unchanged.
+func WithXattrSizeLimit(maxBytes int) Option {
+	return func(o *Options) {
+		o.XattrSizeLimit = maxBytes
+	}
+}
+
+// WithXattrOversizePolicy sets the policy for extended attribute values
+// exceeding the limit set with WithXattrSizeLimit. The default is
+// OversizeError.
+func WithXattrOversizePolicy(policy OversizePolicy) Option {
+	return func(o *Options) {
+		o.XattrOversize = policy
+	}
+}
+
+// WithBlockingFactor makes archive creation pad the tar stream with zero
+// blocks after the end-of-archive marker until its size is a multiple of n
+// blocks of 512 bytes. GNU tar uses a blocking factor of 20 by default. For
+// compressed archives the uncompressed tar stream is padded.
+func WithBlockingFactor(n int) Option {
+	return func(o *Options) {
+		o.BlockingFactor = n
+	}
+}
+
+// WithSkipUnsupportedXattr makes create and extract operations log a warning
+// instead of failing when a filesystem does not support extended attributes.
+// The affected files are archived or extracted without them.
+func WithSkipUnsupportedXattr() Option {
+	return func(o *Options) {
+		o.SkipUnsupportedXattr = true
+	}
+}
+
+// WithVerifyAfterWrite makes archive creation read back the content of every
+// regular file once it has been written to the archive and compare its SHA256
+// hash with the hash of the data read from the file. A
+// *WriteVerificationError is returned on mismatch. This doubles the I/O on the
+// archive and only works for uncompressed archives.
+func WithVerifyAfterWrite() Option {
+	return func(o *Options) {
+		o.VerifyAfterWrite = true
+	}
+}
+
+// WithRecordSize makes archive creation pad the tar stream to a multiple of
+// size bytes like WithBlockingFactor(size / 512). archive/tar only supports
+// 512 byte blocks, so size has to be a multiple of 512. Media using records of
+// other sizes, e.g. 1024 bytes, are served by padding the archive to full
+// records.
+func WithRecordSize(size int) Option {
+	return func(o *Options) {
+		o.RecordSize = size
+	}
+}
+
+// WithCreateDestination makes extraction create a missing destination
+// directory, including its parents, with mode before extracting the first
+// entry. The process umask does not apply to the destination itself.
+func WithCreateDestination(mode os.FileMode) Option {
+	return func(o *Options) {
+		o.CreateDestinationMode = mode
+	}
+}
+
+// WithXattrValueEncoding makes archive creation store extended attribute
+// values encoded with enc. The encoding is recorded in a PAX record of every
+// entry carrying extended attributes and extraction decodes the values
+// accordingly. By default values are base64url encoded.
+func WithXattrValueEncoding(enc XattrEncoding) Option {
+	return func(o *Options) {
+		o.XattrEncoding = enc
+	}
+}
+
+// WithSkipPathValidation makes WriteHeader and archive creation write entry
+// names without checking them with IsValidTarPath first.
+func WithSkipPathValidation() Option {
+	return func(o *Options) {
+		o.SkipPathValidation = true
+	}
+}
+
+// WithVerifyEntryHash makes extraction compare the SHA256 hash of every
+// extracted regular file with the hex encoded hash stored in its tarski.sha256
+// PAX record. Entries without the record are not verified. An
+// *EntryHashMismatchError is returned on mismatch.
+func WithVerifyEntryHash() Option {
+	return func(o *Options) {
+		o.VerifyEntryHash = true
+	}
+}
+
+// WithMaxPathDepth makes extraction fail with ErrPathTooDeep for entries whose
+// name has more than n components. It protects against archives creating
+// excessively nested directories. The default limit is 100 and a negative n
+// disables it.
+func WithMaxPathDepth(n int) Option {
+	return func(o *Options) {
+		o.MaxPathDepth = n
+	}
+}
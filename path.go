@@ -0,0 +1,196 @@
+package tarski
+
+import (
+	"archive/tar"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// nameSize and prefixSize are the sizes of the name and prefix fields
+	// of USTAR headers. V7 headers only have the name field.
+	nameSize   = 100
+	prefixSize = 155
+)
+
+// IsValidTarPath checks whether name can be stored as the name of an entry in
+// an archive of the given format. Names must not be empty, contain null bytes
+// or ".." components and must fit into the header fields of V7 and USTAR
+// archives. PAX and GNU archives support names of any length. Unknown formats
+// are treated like PAX. The returned error wraps ErrInvalidTarPath.
+func IsValidTarPath(name string, format tar.Format) error {
+	if name == "" {
+		return fmt.Errorf("Empty name: %w", ErrInvalidTarPath)
+	}
+	if strings.IndexByte(name, 0) >= 0 {
+		return fmt.Errorf("%q contains a null byte: %w", name, ErrInvalidTarPath)
+	}
+	for _, c := range strings.Split(name, "/") {
+		if c == ".." {
+			return fmt.Errorf("%q contains a \"..\" component: %w", name, ErrInvalidTarPath)
+		}
+	}
+
+	switch format {
+	case tarFormatV7:
+		if len(name) > nameSize {
+			return fmt.Errorf("%q exceeds the V7 name limit of %d bytes: %w", name, nameSize, ErrInvalidTarPath)
+		}
+	case tar.FormatUSTAR:
+		if !fitsUSTAR(name) {
+			return fmt.Errorf("%q exceeds the USTAR name limits: %w", name, ErrInvalidTarPath)
+		}
+	}
+
+	return nil
+}
+
+// tarFormatV7 is the tar.Format of V7 archives which archive/tar does not
+// export.
+const tarFormatV7 tar.Format = 1
+
+// validatePath checks the name of h with IsValidTarPath unless path
+// validation has been disabled.
+func (o *Options) validatePath(h *tar.Header) error {
+	if o.SkipPathValidation {
+		return nil
+	}
+
+	return IsValidTarPath(h.Name, h.Format)
+}
+
+// fitsUSTAR reports whether name fits into the name field of a USTAR header,
+// possibly by moving leading directories into the prefix field.
+func fitsUSTAR(name string) bool {
+	if len(name) <= nameSize {
+		return true
+	}
+
+	// The name is split at a slash with the part after it stored in the
+	// name and the part before it in the prefix field.
+	for i := len(name) - nameSize - 1; i < len(name) && i <= prefixSize; i++ {
+		if i > 0 && name[i] == '/' && len(name)-i-1 > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxSymlinks is the number of symbolic links resolveBeneath follows before it
+// gives up. It matches the limit of the kernel.
+const maxSymlinks = 40
+
+// sanitizePath joins name to base and verifies that the result does not escape
+// base. Symbolic links extracted earlier are resolved for all but the last
+// component of name so nothing can be written through a link pointing outside
+// of base either. The returned path has these links resolved. Nothing can
+// escape the root directory as ".." components are cleaned against it.
+func sanitizePath(base string, name string) (string, error) {
+	base = filepath.Clean(base)
+	entry := filepath.Join(base, name)
+
+	if !isBeneath(base, entry) {
+		return "", fmt.Errorf("%s: %w", name, ErrUnsafePath)
+	}
+	if entry == base {
+		return entry, nil
+	}
+
+	dir, err := resolveBeneath(base, filepath.Dir(entry))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	return filepath.Join(dir, filepath.Base(entry)), nil
+}
+
+// isBeneath reports whether the clean path is base or below it.
+func isBeneath(base string, path string) bool {
+	dir := base
+	if !strings.HasSuffix(dir, string(os.PathSeparator)) {
+		dir += string(os.PathSeparator)
+	}
+
+	return path == base || strings.HasPrefix(path, dir)
+}
+
+// resolveBeneath resolves all symbolic links in path which has to be the clean
+// base or below it. ErrUnsafePath is returned if a link leads outside of base.
+// Components that do not exist yet are taken as they are as they can only be
+// created as directories below base.
+func resolveBeneath(base string, path string) (string, error) {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return "", err
+	}
+
+	pending := strings.Split(rel, string(os.PathSeparator))
+	cur := base
+	links := 0
+	missing := false
+	for len(pending) > 0 {
+		c := pending[0]
+		pending = pending[1:]
+
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			if cur == base {
+				if base == string(os.PathSeparator) {
+					continue
+				}
+				return "", ErrUnsafePath
+			}
+			cur = filepath.Dir(cur)
+			continue
+		}
+
+		next := filepath.Join(cur, c)
+		if missing {
+			cur = next
+			continue
+		}
+
+		fi, err := os.Lstat(next)
+		if os.IsNotExist(err) {
+			missing = true
+			cur = next
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if fi.Mode()&os.ModeSymlink == 0 {
+			cur = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("%s: %w", path, unix.ELOOP)
+		}
+
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			target = filepath.Clean(target)
+			if !isBeneath(base, target) {
+				return "", ErrUnsafePath
+			}
+			cur = base
+			if target, err = filepath.Rel(base, target); err != nil {
+				return "", err
+			}
+		}
+		pending = append(strings.Split(target, string(os.PathSeparator)), pending...)
+	}
+
+	return cur, nil
+}
@@ -0,0 +1,216 @@
+package tarski
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsValidTarPath(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	tests := []struct {
+		name   string
+		format tar.Format
+		valid  bool
+	}{
+		{"file", tar.FormatUnknown, true},
+		{"dir/", tar.FormatUnknown, true},
+		{"dir/file", tar.FormatUnknown, true},
+		{"/abs/file", tar.FormatUnknown, true},
+		{"./file", tar.FormatUnknown, true},
+		{"a..b", tar.FormatUnknown, true},
+		{"..file", tar.FormatUnknown, true},
+		{"file..", tar.FormatUnknown, true},
+		{".../file", tar.FormatUnknown, true},
+		{"", tar.FormatUnknown, false},
+		{"..", tar.FormatUnknown, false},
+		{"../file", tar.FormatUnknown, false},
+		{"dir/../file", tar.FormatUnknown, false},
+		{"dir/..", tar.FormatUnknown, false},
+		{"dir/../", tar.FormatUnknown, false},
+		{"/..", tar.FormatUnknown, false},
+		{"fi\x00le", tar.FormatUnknown, false},
+		{"\x00", tar.FormatUnknown, false},
+		{"", tar.FormatPAX, false},
+		{"../file", tar.FormatGNU, false},
+		{"fi\x00le", tar.FormatUSTAR, false},
+		{long, tarFormatV7, true},
+		{long + "a", tarFormatV7, false},
+		{"dir/" + long, tarFormatV7, false},
+		{long, tar.FormatUSTAR, true},
+		{long + "a", tar.FormatUSTAR, false},
+		{"dir/" + long, tar.FormatUSTAR, true},
+		{strings.Repeat("d", 155) + "/" + long, tar.FormatUSTAR, true},
+		{strings.Repeat("d", 156) + "/" + long, tar.FormatUSTAR, false},
+		{strings.Repeat("d", 155) + "/" + long + "a", tar.FormatUSTAR, false},
+		{strings.Repeat("d/", 60) + "file", tar.FormatUSTAR, true},
+		{long + "/", tar.FormatUSTAR, false},
+		{long + "a/", tar.FormatUSTAR, false},
+		{long + "a", tar.FormatPAX, true},
+		{strings.Repeat("d/", 500) + "file", tar.FormatPAX, true},
+		{strings.Repeat("d/", 500) + "file", tar.FormatGNU, true},
+		{strings.Repeat("d/", 500) + "file", tar.FormatUnknown, true},
+		{strings.Repeat("d/", 500) + "../file", tar.FormatPAX, false},
+	}
+
+	for _, test := range tests {
+		err := IsValidTarPath(test.name, test.format)
+		if test.valid && err != nil {
+			t.Fatalf("Expected %q to be valid for %s. Received %v instead.", test.name, test.format, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidTarPath) {
+			t.Fatalf("Expected %q to be invalid for %s. Received %v instead.", test.name, test.format, err)
+		}
+	}
+}
+
+func TestWriteHeaderPathValidation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Lstat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(filepath.Join(t.TempDir(), archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := tar.NewWriter(f)
+
+	if err = WriteHeader(w, file, "../escape", fi); !errors.Is(err, ErrInvalidTarPath) {
+		t.Fatalf("Expected ErrInvalidTarPath. Received %v instead.", err)
+	}
+	if err = WriteHeader(w, file, "../escape", fi, WithSkipPathValidation()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSanitizePath(t *testing.T) {
+	for _, tc := range []struct {
+		base, name, expected string
+	}{
+		{"/dest", "file", "/dest/file"},
+		{"/dest/", "dir/../file", "/dest/file"},
+		{"/dest", "/etc/passwd", "/dest/etc/passwd"},
+		{"/dest", ".", "/dest"},
+		{"/", "../../file", "/file"},
+	} {
+		entry, err := sanitizePath(tc.base, tc.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry != tc.expected {
+			t.Fatalf("Expected %s. Received %s instead.", tc.expected, entry)
+		}
+	}
+
+	for _, name := range []string{"..", "../dest2/file", "dir/../../file", "../../etc/passwd"} {
+		if _, err := sanitizePath("/dest", name); !errors.Is(err, ErrUnsafePath) {
+			t.Fatalf("Expected ErrUnsafePath for %s. Received %v instead.", name, err)
+		}
+	}
+}
+
+func TestExtractUnsafePath(t *testing.T) {
+	parent := t.TempDir()
+	victim := filepath.Join(parent, "victim")
+	if err := os.WriteFile(victim, []byte("victim"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(parent, "dest")
+
+	for _, h := range []*tar.Header{
+		{Typeflag: tar.TypeReg, Name: "../victim", Mode: 0644},
+		{Typeflag: tar.TypeDir, Name: "../escape/", Mode: 0755},
+		{Typeflag: tar.TypeSymlink, Name: "../escape", Linkname: "victim"},
+		{Typeflag: tar.TypeLink, Name: "link", Linkname: "../victim"},
+		{Typeflag: tar.TypeFifo, Name: "dir/../../escape", Mode: 0644},
+	} {
+		tarball := writeTestArchive(t, []testEntry{{h, ""}})
+		err := Extract(tarball, dest, WithOverwrite(OverwriteReplace))
+		if !errors.Is(err, ErrUnsafePath) {
+			t.Fatalf("Expected ErrUnsafePath for %s. Received %v instead.", h.Name, err)
+		}
+	}
+
+	if content, err := os.ReadFile(victim); err != nil || string(content) != "victim" {
+		t.Fatalf("Expected victim to be left alone. Received %q, %v instead.", content, err)
+	}
+	if _, err := os.Lstat(filepath.Join(parent, "escape")); !os.IsNotExist(err) {
+		t.Fatalf("Expected nothing to be extracted outside of the destination.")
+	}
+
+	for _, fn := range []func(string, *tar.Header) error{ExtractDir, ExtractSymlink, ExtractHardLink, ExtractDev, ExtractFIFO} {
+		if err := fn(dest, &tar.Header{Name: "../escape", Linkname: "file"}); !errors.Is(err, ErrUnsafePath) {
+			t.Fatalf("Expected ErrUnsafePath. Received %v instead.", err)
+		}
+	}
+}
+
+func TestExtractThroughSymlink(t *testing.T) {
+	parent := t.TempDir()
+	outside := filepath.Join(parent, "outside")
+	if err := os.Mkdir(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []string{outside, "../outside", "sub/../../outside"} {
+		dest := filepath.Join(parent, "dest")
+		if err := os.RemoveAll(dest); err != nil {
+			t.Fatal(err)
+		}
+
+		tarball := writeTestArchive(t, []testEntry{
+			{&tar.Header{Typeflag: tar.TypeDir, Name: "sub/", Mode: 0755}, ""},
+			{&tar.Header{Typeflag: tar.TypeSymlink, Name: "dir", Linkname: link}, ""},
+			{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/file", Mode: 0644}, "pwned"},
+		})
+		err := Extract(tarball, dest, WithUnsafeSymlinkPolicy(UnsafeSymlinkAllow))
+		if !errors.Is(err, ErrUnsafePath) {
+			t.Fatalf("Expected ErrUnsafePath through %s. Received %v instead.", link, err)
+		}
+
+		for _, h := range []*tar.Header{
+			{Typeflag: tar.TypeDir, Name: "dir", Mode: 0755},
+			{Typeflag: tar.TypeDir, Name: "dir/sub", Mode: 0755},
+			{Typeflag: tar.TypeFifo, Name: "dir/fifo", Mode: 0644},
+		} {
+			tarball = writeTestArchive(t, []testEntry{{h, ""}})
+			if err = Extract(tarball, dest); !errors.Is(err, ErrUnsafePath) {
+				t.Fatalf("Expected ErrUnsafePath for %s through %s. Received %v instead.", h.Name, link, err)
+			}
+		}
+
+		entries, err := os.ReadDir(outside)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("Expected nothing to be written through %s. Found %d entries.", link, len(entries))
+		}
+	}
+
+	dest := t.TempDir()
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeDir, Name: "sub/", Mode: 0755}, ""},
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "in", Linkname: "sub"}, ""},
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "abs", Linkname: filepath.Join(dest, "sub")}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "in/file", Mode: 0644}, "in"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "abs/other", Mode: 0644}, "abs"},
+	})
+	if err := Extract(tarball, dest, WithUnsafeSymlinkPolicy(UnsafeSymlinkAllow)); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"file", "other"} {
+		if _, err := os.Lstat(filepath.Join(dest, "sub", name)); err != nil {
+			t.Fatalf("Expected %s to be extracted through links staying below the destination: %v", name, err)
+		}
+	}
+}
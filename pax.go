@@ -0,0 +1,150 @@
+package tarski
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// CreatePAX creates a tar archive whose headers are all written in the PAX
+// format. Unlike the USTAR format PAX headers can represent large UIDs and GIDs
+// as well as long file names.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreatePAX(archive string, path string, prefix string, opts ...Option) error {
+	return Create(archive, path, prefix, append(opts[:len(opts):len(opts)], WithFormat(tar.FormatPAX))...)
+}
+
+// CreatePAXSHA256 creates a tar archive whose headers are all written in the
+// PAX format and returns its SHA256-hash checksum.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreatePAXSHA256(archive string, path string, prefix string, opts ...Option) ([]byte, error) {
+	return CreateSHA256(archive, path, prefix, append(opts[:len(opts):len(opts)], WithFormat(tar.FormatPAX))...)
+}
+
+// ExtractPAXRecords returns the PAX records of the entry named entryName
+// without extracting it. Trailing slashes are ignored when comparing names.
+// ErrEntryNotFound is returned if the archive does not contain the entry.
+func ExtractPAXRecords(archive string, entryName string) (map[string]string, error) {
+	var records map[string]string
+
+	entryName = strings.TrimSuffix(entryName, "/")
+	err := walkHeaders(archive, func(h *tar.Header) bool {
+		if strings.TrimSuffix(h.Name, "/") != entryName {
+			return true
+		}
+		records = h.PAXRecords
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if records == nil {
+		return nil, ErrEntryNotFound
+	}
+
+	return records, nil
+}
+
+// ExtractAllPAXRecords returns the PAX records of all entries of an archive
+// keyed by entry name. Entries without PAX records are left out.
+func ExtractAllPAXRecords(archive string) (map[string]map[string]string, error) {
+	all := make(map[string]map[string]string)
+
+	err := walkHeaders(archive, func(h *tar.Header) bool {
+		if len(h.PAXRecords) > 0 {
+			all[h.Name] = h.PAXRecords
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// ReadGlobalPAXRecord returns the value of the PAX record key stored in the
+// global PAX headers of an archive, e.g. by WithGlobalPAXRecord. Later headers
+// take precedence. ErrPAXRecordNotFound is returned if no global header holds
+// the record.
+func ReadGlobalPAXRecord(archive string, key string) (value string, err error) {
+	found := false
+
+	err = walkHeaders(archive, func(h *tar.Header) bool {
+		if h.Typeflag != tar.TypeXGlobalHeader {
+			return true
+		}
+		if v, ok := h.PAXRecords[key]; ok {
+			value, found = v, true
+		}
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !found {
+		return "", fmt.Errorf("%s: %w", key, ErrPAXRecordNotFound)
+	}
+
+	return value, nil
+}
+
+// writeGlobalHeader writes the records set with WithGlobalPAXRecord to a global
+// PAX header. Nothing is written if there are no records.
+func writeGlobalHeader(w *tar.Writer, o *Options) error {
+	if len(o.GlobalPAXRecords) == 0 {
+		return nil
+	}
+
+	records := make(map[string]string, len(o.GlobalPAXRecords))
+	for k, v := range o.GlobalPAXRecords {
+		records[k] = v
+	}
+
+	return w.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: records,
+	})
+}
+
+// walkHeaders calls fn with the header of every entry of a possibly compressed
+// archive until it returns false. Headers without PAX records carry an empty,
+// non-nil map.
+func walkHeaders(archive string, fn func(h *tar.Header) bool) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, _, err := decompressReader(f, &Options{})
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	t := tar.NewReader(r)
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if h.PAXRecords == nil {
+			h.PAXRecords = map[string]string{}
+		}
+
+		if !fn(h) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package tarski
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExtractPAXRecords(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	err := Create(tarball, src, src, WithPAXRecord("tarski.build-id", "1234"), WithPAXRecord("tarski.origin", "ci"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"tarski.build-id": "1234", "tarski.origin": "ci"}
+	for _, name := range []string{"file", "dir", "dir/"} {
+		records, err := ExtractPAXRecords(tarball, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(records, expected) {
+			t.Fatalf("Expected %s to carry %v. Received %v instead.", name, expected, records)
+		}
+	}
+
+	if _, err = ExtractPAXRecords(tarball, "missing"); !errors.Is(err, ErrEntryNotFound) {
+		t.Fatalf("Expected ErrEntryNotFound. Received %v instead.", err)
+	}
+
+	all, err := ExtractAllPAXRecords(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("Expected records for 2 entries. Received %v instead.", all)
+	}
+	for name, records := range all {
+		if !reflect.DeepEqual(records, expected) {
+			t.Fatalf("Expected %s to carry %v. Received %v instead.", name, expected, records)
+		}
+	}
+}
+
+func TestGlobalPAXRecords(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	err := Create(tarball, prefix, prefix,
+		WithGlobalPAXRecord("tarski.host", "builder"),
+		WithGlobalPAXRecord("tarski.tool", "tarski 1.0"),
+		WithGlobalPAXRecord("tarski.ctime", "1700000000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]string{
+		"tarski.host":  "builder",
+		"tarski.tool":  "tarski 1.0",
+		"tarski.ctime": "1700000000",
+	} {
+		value, err := ReadGlobalPAXRecord(tarball, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value != expected {
+			t.Fatalf("Expected %s to be %q. Received %q instead.", key, expected, value)
+		}
+	}
+
+	if _, err = ReadGlobalPAXRecord(tarball, "tarski.missing"); !errors.Is(err, ErrPAXRecordNotFound) {
+		t.Fatalf("Expected ErrPAXRecordNotFound. Received %v instead.", err)
+	}
+
+	if err = Extract(tarball, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreatePAX(t *testing.T) {
+	src := t.TempDir()
+	file := filepath.Join(src, "file")
+	if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Lchown(file, 3000000, 3000000); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src, WithFormat(tar.FormatUSTAR)); err == nil {
+		t.Fatalf("Expected the USTAR format to reject large UIDs.")
+	}
+
+	if _, err := CreatePAXSHA256(tarball, src, src); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	h, err := tar.NewReader(f).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Format != tar.FormatPAX || h.Uid != 3000000 || h.Gid != 3000000 {
+		t.Fatalf("Expected PAX header owned by 3000000:3000000. Received %v header owned by %d:%d instead.", h.Format, h.Uid, h.Gid)
+	}
+}
@@ -0,0 +1,148 @@
+package tarski
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+)
+
+// ProgressInfo describes the progress of a create or extract operation.
+type ProgressInfo struct {
+	// Entry is the name of the entry currently being processed.
+	Entry string
+	// EntryIndex is the zero-based index of the current entry.
+	EntryIndex int
+	// TotalEntries is the number of entries of the archive. It is known
+	// during creation and when extracting uncompressed archive files, which
+	// are scanned for it up front. It is -1 when extracting compressed
+	// archives or streams.
+	TotalEntries int
+	// BytesThisEntry is the number of content bytes of the current entry
+	// processed so far.
+	BytesThisEntry int64
+	// TotalBytesThisEntry is the size of the content of the current entry.
+	TotalBytesThisEntry int64
+	// TotalBytesProcessed is the number of content bytes processed so far
+	// across all entries.
+	TotalBytesProcessed int64
+}
+
+// ProgressFunc is called when processing of an entry starts and after each
+// chunk of its content has been processed.
+type ProgressFunc func(info ProgressInfo)
+
+// progress tracks the progress of a single operation. A nil progress discards
+// all updates.
+type progress struct {
+	fn   ProgressFunc
+	info ProgressInfo
+}
+
+func newProgress(fn ProgressFunc, total int) *progress {
+	if fn == nil {
+		return nil
+	}
+
+	return &progress{fn: fn, info: ProgressInfo{EntryIndex: -1, TotalEntries: total}}
+}
+
+// countEntries returns the number of entries other than global headers of the
+// archive f if it is an uncompressed regular file and -1 otherwise. f is left
+// at its start. archive/tar skips the content of entries by seeking so only the
+// headers are read.
+func countEntries(f *os.File) (n int, err error) {
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return -1, err
+	}
+
+	magic := make([]byte, blockSize)
+	if _, err = f.ReadAt(magic, 0); err != nil && err != io.EOF {
+		return
+	}
+	if detectCompression(magic) != CompressionNone {
+		return -1, nil
+	}
+
+	r := tar.NewReader(f)
+	for {
+		var h *tar.Header
+		if h, err = r.Next(); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+		if h.Typeflag != tar.TypeXGlobalHeader {
+			n++
+		}
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	return
+}
+
+// start reports that processing of entry with size bytes of content starts.
+func (p *progress) start(entry string, size int64) {
+	if p == nil {
+		return
+	}
+
+	p.info.Entry = entry
+	p.info.EntryIndex++
+	p.info.BytesThisEntry = 0
+	p.info.TotalBytesThisEntry = size
+	p.fn(p.info)
+}
+
+// add reports that n more bytes of content of the current entry have been
+// processed.
+func (p *progress) add(n int) {
+	if p == nil || n == 0 {
+		return
+	}
+
+	p.info.BytesThisEntry += int64(n)
+	p.info.TotalBytesProcessed += int64(n)
+	p.fn(p.info)
+}
+
+// writer wraps w so that all bytes written are reported.
+func (p *progress) writer(w io.Writer) io.Writer {
+	if p == nil {
+		return w
+	}
+
+	return &progressWriter{w, p}
+}
+
+// reader wraps r so that all bytes read are reported.
+func (p *progress) reader(r io.Reader) io.Reader {
+	if p == nil {
+		return r
+	}
+
+	return &progressReader{r, p}
+}
+
+type progressWriter struct {
+	w io.Writer
+	p *progress
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.p.add(n)
+	return n, err
+}
+
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.r.Read(b)
+	pr.p.add(n)
+	return n, err
+}
@@ -0,0 +1,84 @@
+package tarski
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	src := t.TempDir()
+	sizes := map[string]int{"a": 10, "b": 0, "dir/c": 100000}
+	var total int64
+	for name, size := range sizes {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		total += int64(size)
+	}
+
+	var infos []ProgressInfo
+	record := func(info ProgressInfo) {
+		infos = append(infos, info)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src, WithProgress(record)); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, infos, 4, total)
+
+	infos = nil
+	if err := Extract(tarball, t.TempDir(), WithProgress(record)); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, infos, 4, total)
+
+	compressed := filepath.Join(t.TempDir(), archive+".gz")
+	if err := CreateGzip(compressed, src, src); err != nil {
+		t.Fatal(err)
+	}
+	infos = nil
+	if err := Extract(compressed, t.TempDir(), WithProgress(record)); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, infos, -1, total)
+}
+
+func checkProgress(t *testing.T, infos []ProgressInfo, totalEntries int, totalBytes int64) {
+	if len(infos) == 0 {
+		t.Fatalf("Expected progress to be reported.")
+	}
+
+	index := -1
+	var processed int64
+	for i, info := range infos {
+		if info.TotalEntries != totalEntries {
+			t.Fatalf("Expected %d total entries. Received %d instead.", totalEntries, info.TotalEntries)
+		}
+		if info.EntryIndex != index {
+			if info.EntryIndex != index+1 || info.BytesThisEntry != 0 {
+				t.Fatalf("Expected entry %d to start at zero bytes. Received %+v instead.", index+1, info)
+			}
+			if i > 0 && infos[i-1].BytesThisEntry != infos[i-1].TotalBytesThisEntry {
+				t.Fatalf("Expected entry %s to be processed completely. Received %+v instead.", infos[i-1].Entry, infos[i-1])
+			}
+			index = info.EntryIndex
+		}
+		if info.TotalBytesProcessed < processed {
+			t.Fatalf("Expected processed bytes to grow monotonically.")
+		}
+		processed = info.TotalBytesProcessed
+	}
+
+	if totalEntries >= 0 && index != totalEntries-1 {
+		t.Fatalf("Expected %d entries to be reported. Received %d instead.", totalEntries, index+1)
+	}
+	if processed != totalBytes {
+		t.Fatalf("Expected %d bytes to be processed. Received %d instead.", totalBytes, processed)
+	}
+}
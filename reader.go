@@ -0,0 +1,154 @@
+package tarski
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// blockSize is the size of a tar block.
+const blockSize = 512
+
+// Reader reads a tar archive entry by entry while computing a SHA256-hash
+// checksum of the consumed tar stream.
+type Reader struct {
+	tr  *tar.Reader
+	hr  *hashingReader
+	cur *tar.Header
+
+	// e extracts the entries passed to ExtractCurrent. It tracks the
+	// extended attribute encoding announced by global headers and the
+	// directories whose metadata is restored at the end.
+	e *extractor
+}
+
+// NewReader creates a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	hr := &hashingReader{r: r, h: sha256.New(), mark: -1}
+	hr.sum = hr.h.Sum(nil)
+
+	return &Reader{tr: tar.NewReader(hr), hr: hr, e: newExtractor("", &Options{})}
+}
+
+// Next advances to the next entry in the tar archive. Any content of the
+// current entry that has not been read is skipped. io.EOF is returned at the
+// end of the archive once the modes and times of the directories extracted
+// with ExtractCurrent have been restored.
+func (r *Reader) Next() (*tar.Header, error) {
+	if r.cur != nil {
+		if _, err := io.Copy(io.Discard, r.tr); err != nil {
+			return nil, err
+		}
+
+		// The content of an entry is padded to a full block.
+		r.hr.markAt(r.hr.n + (blockSize-r.hr.n%blockSize)%blockSize)
+	}
+
+	h, err := r.tr.Next()
+	if err == io.EOF {
+		r.hr.sum = r.hr.h.Sum(nil)
+		if cerr := r.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	r.cur = h
+
+	if err == nil && h.Typeflag == tar.TypeXGlobalHeader {
+		err = r.e.extract(h, r.tr)
+	}
+
+	return h, err
+}
+
+// Close restores the modes and times of the directories extracted with
+// ExtractCurrent. Directories are created writable and keep the time of the
+// last entry extracted into them until then. It only needs to be called if
+// reading stops before Next() returns io.EOF. The underlying reader is not
+// closed.
+func (r *Reader) Close() error {
+	dirs := r.e.dirs
+	r.e.dirs = nil
+
+	return restoreDirs(dirs)
+}
+
+// Read reads from the content of the current entry.
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.tr.Read(p)
+}
+
+// ReadContent copies the content of the current entry to w.
+func (r *Reader) ReadContent(w io.Writer) (int64, error) {
+	return io.Copy(w, r.tr)
+}
+
+// ExtractCurrent extracts the current entry under destPath and advances past
+// its content.
+func (r *Reader) ExtractCurrent(destPath string) error {
+	if r.cur == nil {
+		return errors.New("No current entry to extract.")
+	}
+
+	if r.cur.Typeflag == tar.TypeXGlobalHeader {
+		return nil
+	}
+
+	r.e.path = destPath
+	if err := r.e.extract(r.cur, r.tr); err != nil {
+		return fmt.Errorf("entry %q: %w", r.cur.Name, err)
+	}
+
+	_, err := io.Copy(io.Discard, r.tr)
+	return err
+}
+
+// CurrentHash returns the SHA256-hash checksum of the tar stream up to and
+// including the last fully consumed entry. Immediately after Next() it is the
+// checksum up to the end of the previous entry. Once Next() has returned
+// io.EOF it is the checksum of the whole tar stream and matches the checksum
+// returned by CreateSHA256 and ExtractSHA256.
+func (r *Reader) CurrentHash() []byte {
+	return append([]byte(nil), r.hr.sum...)
+}
+
+// hashingReader hashes everything read from r and records a snapshot of the
+// hash once the stream position reaches mark.
+type hashingReader struct {
+	r    io.Reader
+	h    hash.Hash
+	n    int64
+	mark int64
+	sum  []byte
+}
+
+func (hr *hashingReader) markAt(pos int64) {
+	if pos <= hr.n {
+		hr.sum = hr.h.Sum(nil)
+		hr.mark = -1
+		return
+	}
+
+	hr.mark = pos
+}
+
+func (hr *hashingReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	b := p[:n]
+
+	if hr.mark >= 0 && hr.n+int64(len(b)) >= hr.mark {
+		k := hr.mark - hr.n
+		hr.h.Write(b[:k])
+		hr.sum = hr.h.Sum(nil)
+		hr.mark = -1
+		hr.n += k
+		b = b[k:]
+	}
+
+	hr.h.Write(b)
+	hr.n += int64(len(b))
+
+	return n, err
+}
@@ -0,0 +1,173 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReaderCurrentHash(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	checksum, err := CreateSHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(bytes.NewReader(b))
+	empty := sha256.Sum256(nil)
+	if !bytes.Equal(r.CurrentHash(), empty[:]) {
+		t.Fatalf("Expected checksum of the empty stream before the first entry.")
+	}
+
+	var prev []byte
+	for i := 0; ; i++ {
+		_, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Calling CurrentHash() before reading the content must be
+		// stable.
+		cur := r.CurrentHash()
+		if i > 0 && bytes.Equal(cur, prev) {
+			t.Fatalf("Expected checksum to change after entry %d.", i-1)
+		}
+		if !isBlockPrefixHash(b, cur) {
+			t.Fatalf("Expected checksum of entry %d to cover a block aligned prefix of the stream.", i)
+		}
+		if _, err = r.ReadContent(io.Discard); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(cur, r.CurrentHash()) {
+			t.Fatalf("Expected checksum not to change while reading content of entry %d.", i)
+		}
+		prev = cur
+	}
+
+	if !bytes.Equal(r.CurrentHash(), checksum) {
+		t.Fatalf("Expected checksum %x. Received %x instead.", checksum, r.CurrentHash())
+	}
+}
+
+func isBlockPrefixHash(b []byte, sum []byte) bool {
+	for k := 0; k <= len(b); k += blockSize {
+		s := sha256.Sum256(b[:k])
+		if bytes.Equal(s[:], sum) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestReaderExtractCurrent(t *testing.T) {
+	var content []testEntry
+	for _, name := range []string{"zero", "one", "two", "three", "four"} {
+		content = append(content, testEntry{
+			header:  &tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644},
+			content: "content of " + name,
+		})
+	}
+
+	f, err := os.Open(writeTestArchive(t, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	dest := t.TempDir()
+	r := NewReader(f)
+	for i := 0; ; i++ {
+		_, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i%2 == 0 {
+			if err = r.ExtractCurrent(dest); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for i, e := range content {
+		b, err := os.ReadFile(filepath.Join(dest, e.header.Name))
+		if i%2 == 1 {
+			if !os.IsNotExist(err) {
+				t.Fatalf("Expected %s not to be extracted.", e.header.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != e.content {
+			t.Fatalf("Expected %s to contain %q. Found %q instead.", e.header.Name, e.content, string(b))
+		}
+	}
+}
+
+func TestReaderExtractCurrentMetadata(t *testing.T) {
+	mtime := time.Unix(1500000000, 0)
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeXGlobalHeader, PAXRecords: map[string]string{paxXattrEncoding: "hex"}}, ""},
+		{&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0555, ModTime: mtime}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/file", Mode: 0644, Xattrs: map[string]string{"user.test": "76616c7565"}, Format: tar.FormatPAX}, "data"},
+	})
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	dest := t.TempDir()
+	r := NewReader(f)
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = r.ExtractCurrent(dest); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fi, err := os.Stat(filepath.Join(dest, "dir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0555 {
+		t.Fatalf("Expected mode 0555. Received %o instead.", fi.Mode().Perm())
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Fatalf("Expected modification time %v. Received %v instead.", mtime, fi.ModTime())
+	}
+
+	value, err := GetXattr(filepath.Join(dest, "dir", "file"), "user.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "value" {
+		t.Fatalf("Expected extended attribute user.test to be %q. Received %q instead.", "value", value)
+	}
+}
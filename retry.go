@@ -0,0 +1,57 @@
+package tarski
+
+import (
+	"archive/tar"
+	"io"
+	"maps"
+	"os"
+	"time"
+)
+
+// extractRetry extracts the entry described by h retrying failures accepted by
+// the predicate set with WithRetryOnError. Entries are only retried while none
+// of their content has been consumed since it cannot be read again. Every
+// attempt works on a copy of h since extract changes the header it is given.
+// Entries that did not exist before the first attempt are removed again before
+// retrying so that what a failed attempt left behind does not make the next one
+// fail.
+func (e *extractor) extractRetry(h *tar.Header, r io.Reader) error {
+	if e.o.RetryMax <= 0 {
+		return e.extract(h, r)
+	}
+
+	c := &countingReader{r: r}
+	e.created = ""
+	err := e.extract(copyHeader(h), c)
+	created := e.created
+
+	backoff := e.o.RetryBackoff
+	for i := 0; i < e.o.RetryMax && err != nil && c.n == 0; i++ {
+		if e.o.RetryPredicate == nil || !e.o.RetryPredicate(err) {
+			break
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+
+		if created != "" {
+			if rerr := os.Remove(created); rerr != nil && !os.IsNotExist(rerr) {
+				break
+			}
+		}
+
+		err = e.extract(copyHeader(h), c)
+	}
+
+	return err
+}
+
+// copyHeader returns a copy of h whose maps can be changed without affecting
+// h.
+func copyHeader(h *tar.Header) *tar.Header {
+	c := *h
+	c.Xattrs = maps.Clone(h.Xattrs)
+	c.PAXRecords = maps.Clone(h.PAXRecords)
+
+	return &c
+}
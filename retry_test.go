@@ -0,0 +1,164 @@
+package tarski
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// failingOpenFile makes the first failures calls to openFile fail with EIO.
+func failingOpenFile(t *testing.T, failures int) *int {
+	calls := 0
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		calls++
+		if calls <= failures {
+			return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EIO}
+		}
+		return os.OpenFile(name, flag, perm)
+	}
+	t.Cleanup(func() {
+		openFile = os.OpenFile
+	})
+
+	return &calls
+}
+
+func TestExtractRetryOnError(t *testing.T) {
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "file", Mode: 0644}, "content"},
+	})
+	transient := func(err error) bool {
+		return errors.Is(err, syscall.EIO)
+	}
+
+	calls := failingOpenFile(t, 2)
+	dest := t.TempDir()
+	if err := Extract(tarball, dest, WithRetryOnError(transient, 3, time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 3 {
+		t.Fatalf("Expected extraction to succeed on the third attempt. Received %d attempts instead.", *calls)
+	}
+	b, err := os.ReadFile(filepath.Join(dest, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "content" {
+		t.Fatalf("Expected %q. Received %q instead.", "content", b)
+	}
+
+	calls = failingOpenFile(t, 2)
+	if err = Extract(tarball, t.TempDir(), WithRetryOnError(transient, 1, time.Millisecond)); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("Expected EIO once the retries are exhausted. Received %v instead.", err)
+	}
+	if *calls != 2 {
+		t.Fatalf("Expected 2 attempts. Received %d instead.", *calls)
+	}
+
+	calls = failingOpenFile(t, 2)
+	never := func(error) bool { return false }
+	if err = Extract(tarball, t.TempDir(), WithRetryOnError(never, 3, time.Millisecond)); !errors.Is(err, syscall.EIO) {
+		t.Fatalf("Expected EIO without retrying. Received %v instead.", err)
+	}
+	if *calls != 1 {
+		t.Fatalf("Expected a single attempt. Received %d instead.", *calls)
+	}
+}
+
+func TestExtractRetryAfterCreate(t *testing.T) {
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "empty", Mode: 0644}, ""},
+	})
+
+	// The first attempt creates the file before failing.
+	calls := 0
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		calls++
+		f, err := os.OpenFile(name, flag, perm)
+		if err != nil || calls > 1 {
+			return f, err
+		}
+		f.Close()
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EIO}
+	}
+	t.Cleanup(func() {
+		openFile = os.OpenFile
+	})
+
+	transient := func(err error) bool {
+		return errors.Is(err, syscall.EIO)
+	}
+	dest := t.TempDir()
+	if err := Extract(tarball, dest, WithRetryOnError(transient, 3, time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("Expected extraction to succeed on the second attempt. Received %d attempts instead.", calls)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "empty")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractRetryHeaderUnchanged(t *testing.T) {
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{
+			Typeflag:   tar.TypeReg,
+			Name:       "a/b/file",
+			Mode:       0644,
+			Uid:        10,
+			Xattrs:     map[string]string{"user.k": "dmFsdWU="},
+			PAXRecords: map[string]string{paxXattrEncoding: "base64"},
+			Format:     tar.FormatPAX,
+		}, ""},
+	})
+
+	calls := 0
+	openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+		calls++
+		f, err := os.OpenFile(name, flag, perm)
+		if err != nil || calls > 1 {
+			return f, err
+		}
+		f.Close()
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EIO}
+	}
+	t.Cleanup(func() {
+		openFile = os.OpenFile
+	})
+
+	transient := func(err error) bool {
+		return errors.Is(err, syscall.EIO)
+	}
+	dest := t.TempDir()
+	err := Extract(tarball, dest,
+		WithRetryOnError(transient, 3, time.Millisecond),
+		WithStripComponents(1),
+		WithIDMap(func(id int) int { return id + 1 }, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("Expected extraction to succeed on the second attempt. Received %d attempts instead.", calls)
+	}
+
+	file := filepath.Join(dest, "b", "file")
+	fi, err := os.Lstat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid := fi.Sys().(*syscall.Stat_t).Uid; uid != 11 {
+		t.Fatalf("Expected owner 11. Received %d instead.", uid)
+	}
+	value, err := GetXattr(file, "user.k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "value" {
+		t.Fatalf("Expected extended attribute user.k to be %q. Received %q instead.", "value", value)
+	}
+}
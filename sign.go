@@ -0,0 +1,140 @@
+package tarski
+
+import (
+	"archive/tar"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// paxSignature is the global PAX record holding the signature of an archive.
+const paxSignature = "tarski.signature"
+
+// CreateAndSign creates a tar archive, computes its SHA256-hash checksum and
+// signs the checksum with privKey. The signature is appended to the archive as
+// a global PAX header so the checksum covers the uncompressed tar stream up to,
+// but not including, the signature entry.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateAndSign(archive string, path string, prefix string, privKey ed25519.PrivateKey, opts ...Option) (checksum, signature []byte, err error) {
+	o := newOptions(opts)
+	b := sha256.New()
+
+	err = createArchiveWith(archive, b, o, func(w *tar.Writer) error {
+		if err := doCreate(w, path, prefix, o); err != nil {
+			return err
+		}
+
+		// Write out the padding of the last entry so it is covered by
+		// the checksum.
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		checksum = b.Sum(nil)
+		signature = ed25519.Sign(privKey, checksum)
+
+		return w.WriteHeader(&tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			Name:       paxSignature,
+			PAXRecords: map[string]string{paxSignature: hex.EncodeToString(signature)},
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return checksum, signature, nil
+}
+
+// VerifySignature reports whether signature is a valid signature of checksum
+// by pubKey.
+func VerifySignature(checksum, signature []byte, pubKey ed25519.PublicKey) bool {
+	return ed25519.Verify(pubKey, checksum, signature)
+}
+
+// ExtractVerified verifies the signature of an archive created by
+// CreateAndSign and extracts it under path if the signature is valid.
+// ErrSignatureMissing is returned for archives that do not end with a
+// signature and ErrInvalidSignature for archives whose signature does not
+// verify. The archive is copied to an unlinked temporary file while it is
+// verified and extracted from that copy, so changes made to archive after
+// verification are never extracted.
+func ExtractVerified(archive string, path string, pubKey ed25519.PublicKey, opts ...Option) error {
+	o := newOptions(opts)
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	verified, err := os.CreateTemp("", "tarski-verified-")
+	if err != nil {
+		return err
+	}
+	defer verified.Close()
+	if err = os.Remove(verified.Name()); err != nil {
+		return err
+	}
+
+	c, _, err := decompressReader(io.TeeReader(f, verified), o)
+	if err != nil {
+		return err
+	}
+	checksum, signature, err := readSignature(c)
+	c.Close()
+	if err != nil {
+		return err
+	}
+
+	if !VerifySignature(checksum, signature, pubKey) {
+		return ErrInvalidSignature
+	}
+
+	// Copy whatever follows the end of the tar stream as well.
+	if _, err = io.Copy(verified, f); err != nil {
+		return err
+	}
+
+	if _, err = verified.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return extractStream(verified, path, nil, o)
+}
+
+// readSignature reads the signature of an archive and the checksum of the tar
+// stream preceding it.
+func readSignature(r io.Reader) (checksum, signature []byte, err error) {
+	t := NewReader(r)
+
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if h.Typeflag == tar.TypeXGlobalHeader && h.PAXRecords[paxSignature] != "" {
+			checksum = t.CurrentHash()
+			signature, err = hex.DecodeString(h.PAXRecords[paxSignature])
+			if err != nil {
+				return nil, nil, ErrInvalidSignature
+			}
+			continue
+		}
+
+		// Entries following the signature are not covered by it.
+		signature = nil
+	}
+
+	if signature == nil {
+		return nil, nil, ErrSignatureMissing
+	}
+
+	return checksum, signature, nil
+}
@@ -0,0 +1,81 @@
+package tarski
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateAndSign(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	checksum, signature, err := CreateAndSign(tarball, prefix, prefix, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !VerifySignature(checksum, signature, pub) {
+		t.Fatalf("Expected signature to verify.")
+	}
+
+	dest := t.TempDir()
+	if err = ExtractVerified(tarball, dest, pub); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dest, entries[1])); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dest, paxSignature)); !os.IsNotExist(err) {
+		t.Fatalf("Expected signature entry not to be extracted.")
+	}
+
+	other, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ExtractVerified(tarball, t.TempDir(), other); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Expected ErrInvalidSignature. Received %v instead.", err)
+	}
+
+	unsigned := filepath.Join(t.TempDir(), archive)
+	if err = Create(unsigned, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+	if err = ExtractVerified(unsigned, t.TempDir(), pub); !errors.Is(err, ErrSignatureMissing) {
+		t.Fatalf("Expected ErrSignatureMissing. Received %v instead.", err)
+	}
+}
+
+func TestCreateAndSignCompressed(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if _, _, err = CreateAndSign(tarball, prefix, prefix, priv, WithGzip()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := detectCompression(data); c != CompressionGzip {
+		t.Fatalf("Expected a gzip compressed archive. Received %s instead.", c)
+	}
+
+	dest := t.TempDir()
+	if err = ExtractVerified(tarball, dest, pub); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dest, entries[1])); err != nil {
+		t.Fatal(err)
+	}
+}
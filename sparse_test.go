@@ -0,0 +1,83 @@
+package tarski
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// writeOldGNUSparseArchive writes an archive holding a single old GNU sparse
+// entry of realSize bytes whose only data fragment is data at offset.
+// archive/tar cannot write sparse entries so the header is crafted by hand.
+func writeOldGNUSparseArchive(t *testing.T, name string, data string, offset int64, realSize int64) string {
+	hdr := make([]byte, blockSize)
+	octal := func(b []byte, v int64) {
+		copy(b, fmt.Sprintf("%0*o", len(b)-1, v))
+	}
+
+	copy(hdr[0:100], name)
+	octal(hdr[100:108], 0644)
+	octal(hdr[108:116], 0)
+	octal(hdr[116:124], 0)
+	octal(hdr[124:136], int64(len(data)))
+	octal(hdr[136:148], 0)
+	hdr[156] = 'S'
+	copy(hdr[257:265], magicGNU)
+	octal(hdr[386:398], offset)
+	octal(hdr[398:410], int64(len(data)))
+	octal(hdr[483:495], realSize)
+
+	copy(hdr[148:156], "        ")
+	var sum int64
+	for _, c := range hdr {
+		sum += int64(c)
+	}
+	copy(hdr[148:156], fmt.Sprintf("%06o\x00 ", sum))
+
+	content := make([]byte, blockSize)
+	copy(content, data)
+
+	b := append(hdr, content...)
+	b = append(b, make([]byte, 2*blockSize)...)
+
+	archive := filepath.Join(t.TempDir(), "sparse.tar")
+	if err := os.WriteFile(archive, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return archive
+}
+
+func TestExtractGNUSparse(t *testing.T) {
+	const offset, size = 32768, 65536
+
+	archive := writeOldGNUSparseArchive(t, "sparse", "hello", offset, size)
+
+	dest := t.TempDir()
+	if err := Extract(archive, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dest, "sparse")
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := make([]byte, size)
+	copy(expected[offset:], "hello")
+	if !bytes.Equal(b, expected) {
+		t.Fatalf("Expected %d bytes with data at offset %d. Received %d bytes instead.", size, offset, len(b))
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocated := fi.Sys().(*syscall.Stat_t).Blocks * 512; allocated >= size {
+		t.Fatalf("Expected holes to be preserved. Found %d bytes allocated for a %d byte file.", allocated, size)
+	}
+}
@@ -9,18 +9,36 @@ package tarski
 
 import (
 	"archive/tar"
+	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"golang.org/x/sys/unix"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
-	"unsafe"
 )
 
+// typeGNUVolHeader is the type flag GNU tar uses for volume headers. It is not
+// defined by archive/tar.
+const typeGNUVolHeader byte = 'V'
+
+// paxEntrySHA256 is the PAX record holding the hex encoded SHA256 hash of the
+// content of an entry.
+const paxEntrySHA256 = "tarski.sha256"
+
+// typeGNUDumpDir is the type flag GNU tar uses for directory listings in
+// incremental archives. It is not defined by archive/tar.
+const typeGNUDumpDir byte = 'D'
+
 // IsEmpty detects empty tar archives.
 func IsEmpty(archive string) (bool, error) {
 	f, err := os.Open(archive)
@@ -38,216 +56,1259 @@ func IsEmpty(archive string) (bool, error) {
 	return false, err
 }
 
+// IsDataEmpty detects tar archives without data. Unlike IsEmpty archives
+// containing only directories, global PAX headers or volume headers are
+// considered empty as well. Regular files, links, devices and FIFOs make an
+// archive non-empty.
+func IsDataEmpty(archive string) (bool, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	t := tar.NewReader(f)
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		switch h.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader, typeGNUVolHeader:
+			continue
+		}
+
+		return false, nil
+	}
+}
+
+// ResetArchive truncates an existing tar archive to zero entries. The file is
+// kept and only holds the end-of-archive marker afterwards.
+func ResetArchive(archive string) error {
+	if err := os.Truncate(archive, 0); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(archive, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(make([]byte, 2*blockSize)); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
 // CreateSHA256 creates a tar archive and returns its SHA256-hash checksum.
 // The SHA256 hash of the tar archive is created based on the tar stream and not
 // simply on the resulting archive. This is a proper content hash.
 // The string given by prefix will be stripped from all entries found under
 // path.
-func CreateSHA256(archive string, path string, prefix string) (checksum []byte, err error) {
-	a, err := os.Create(archive)
+func CreateSHA256(archive string, path string, prefix string, opts ...Option) ([]byte, error) {
+	return CreateWithHash(archive, path, prefix, sha256.New(), opts...)
+}
+
+// Create creates a tar archive.
+// The string given by prefix will be stripped from all entries found under
+// path. Creating the same archive concurrently fails with ErrArchiveInProgress.
+func Create(archive string, path string, prefix string, opts ...Option) (err error) {
+	return createArchive(archive, path, prefix, nil, newOptions(opts))
+}
+
+// CreateWithOptions creates a tar archive configured by o. It is equivalent to
+// Create with the corresponding Option functions. If o.HashAlgorithm is not nil
+// the tar stream is written to it as well.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateWithOptions(archive string, path string, prefix string, o Options) error {
+	return createArchive(archive, path, prefix, o.HashAlgorithm, &o)
+}
+
+// CreateToWriter writes a tar archive of path to w. Nothing but path is
+// accessed on the filesystem so w can be e.g. a network connection or an
+// in-memory buffer.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateToWriter(w io.Writer, path string, prefix string, opts ...Option) error {
+	return createStream(w, path, prefix, nil, newOptions(opts))
+}
+
+// CreateSHA256ToWriter writes a tar archive of path to w and returns the
+// SHA256-hash checksum of the uncompressed tar stream.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateSHA256ToWriter(w io.Writer, path string, prefix string, opts ...Option) (checksum []byte, err error) {
+	b := sha256.New()
+	if err = createStream(w, path, prefix, b, newOptions(opts)); err != nil {
+		return
+	}
+
+	return b.Sum(nil), nil
+}
+
+// createArchive creates a tar archive compressed as selected in o. If h is
+// not nil the uncompressed tar stream is written to it as well.
+// ErrArchiveInProgress is returned if archive is already being created by
+// another call in this process.
+func createArchive(archive string, path string, prefix string, h io.Writer, o *Options) error {
+	return createArchiveWith(archive, h, o, func(w *tar.Writer) error {
+		return doCreate(w, path, prefix, o)
+	})
+}
+
+// createArchiveWith creates archive like createArchive with the entries
+// written by fn.
+func createArchiveWith(archive string, h io.Writer, o *Options, fn func(w *tar.Writer) error) (err error) {
+	unlock, err := lockArchive(archive)
 	if err != nil {
 		return
 	}
-	defer a.Close()
+	defer unlock()
 
-	b := sha256.New()
-	c := io.MultiWriter(a, b)
-	d := tar.NewWriter(c)
+	f, err := os.Create(archive)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if err = createStreamWith(f, h, o, fn); err != nil {
+		return
+	}
+
+	return f.Close()
+}
+
+// archivesInProgress holds the resolved absolute paths of the archives being
+// created.
+var archivesInProgress sync.Map
+
+// lockArchive records archive as being created. It fails with
+// ErrArchiveInProgress if it already is. The returned function releases the
+// archive again.
+func lockArchive(archive string) (func(), error) {
+	abs, err := filepath.Abs(archive)
+	if err != nil {
+		return nil, err
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		abs = filepath.Join(dir, filepath.Base(abs))
+	}
+
+	if _, loaded := archivesInProgress.LoadOrStore(abs, struct{}{}); loaded {
+		return nil, fmt.Errorf("%s: %w", archive, ErrArchiveInProgress)
+	}
 
-	err = doCreate(d, path, prefix)
+	return func() { archivesInProgress.Delete(abs) }, nil
+}
+
+// createStream writes a tar archive compressed as selected in o to dst. If h
+// is not nil the uncompressed tar stream is written to it as well.
+func createStream(dst io.Writer, path string, prefix string, h io.Writer, o *Options) error {
+	return createStreamWith(dst, h, o, func(w *tar.Writer) error {
+		return doCreate(w, path, prefix, o)
+	})
+}
+
+// createStreamWith writes a tar archive like createStream with the entries
+// written by fn. The tar writer is closed and the archive padded afterwards.
+func createStreamWith(dst io.Writer, h io.Writer, o *Options, fn func(w *tar.Writer) error) (err error) {
+	record := o.BlockingFactor * blockSize
+	if o.RecordSize != 0 {
+		if o.RecordSize < 0 || o.RecordSize%blockSize != 0 {
+			return ErrInvalidRecordSize
+		}
+		record = o.RecordSize
+	}
+
+	if o.VerifyAfterWrite {
+		rws, ok := dst.(io.ReadWriteSeeker)
+		if !ok || o.Compression != CompressionNone {
+			return errors.New("Verifying after write requires an uncompressed archive that can be read back.")
+		}
+		o.verify = rws
+	}
+
+	c, err := compressWriter(dst, o)
 	if err != nil {
 		return
 	}
 
-	if err = d.Close(); err != nil {
+	var d io.Writer = c
+	if h != nil {
+		d = io.MultiWriter(c, h)
+	}
+	cw := &countingWriter{w: d}
+	w := tar.NewWriter(cw)
+
+	if err = fn(w); err != nil {
 		return
 	}
 
-	return b.Sum(nil), nil
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	if err = padRecord(cw, cw.n, record); err != nil {
+		return
+	}
+
+	return c.Close()
 }
 
-// Create creates a tar archive.
-// The string given by prefix will be stripped from all entries found under
-// path.
-func Create(archive string, path string, prefix string) (err error) {
-	f, err := os.Create(archive)
+// padRecord writes zeros to w until written is a multiple of record.
+func padRecord(w io.Writer, written int64, record int) error {
+	if record <= 0 {
+		return nil
+	}
+
+	if rem := written % int64(record); rem != 0 {
+		_, err := w.Write(make([]byte, int64(record)-rem))
+		return err
+	}
+
+	return nil
+}
+
+// WriteHeader writes a tar header.
+// Deals with symbolic links and extended attributes.
+// The entry argument will become the name of the file, directory, etc. in the
+// tar header. It is checked with IsValidTarPath unless WithSkipPathValidation()
+// is passed.
+func WriteHeader(w *tar.Writer, path string, entry string, f os.FileInfo, opts ...Option) (err error) {
+	return writeHeader(w, path, entry, f, newOptions(opts), nil)
+}
+
+// writeHeader writes the tar header of the file at path. If g is not nil the
+// extended attributes are retrieved through it.
+func writeHeader(w *tar.Writer, path string, entry string, f os.FileInfo, o *Options, g *os.File) (err error) {
+	h, err := fileHeader(path, entry, f, o, g)
+	if err != nil {
+		return
+	}
+
+	if err = o.validatePath(h); err != nil {
+		return
+	}
+
+	return w.WriteHeader(h)
+}
+
+// fileHeader creates the tar header for the file at path under the name entry.
+// If g is not nil the extended attributes are retrieved through it instead of
+// looking up path again.
+func fileHeader(path string, entry string, f os.FileInfo, o *Options, g *os.File) (h *tar.Header, err error) {
+	var link string
+
+	if f.Mode()&os.ModeSymlink == os.ModeSymlink {
+		link, err = os.Readlink(path)
+		if err != nil {
+			return
+		}
+	}
+
+	h, err = tar.FileInfoHeader(f, link)
 	if err != nil {
 		return
 	}
-	defer f.Close()
 
-	w := tar.NewWriter(f)
+	if err = deviceNumbers(h, path); err != nil {
+		return
+	}
+
+	h.Name = entry
+	if g != nil {
+		var raw map[string][]byte
+		raw, err = GetAllXattrFromFd(g.Fd())
+		h.Xattrs, err = xattrStrings(raw), unsupportedXattr(path, err)
+	} else {
+		h.Xattrs, err = GetAllXattr(path)
+	}
+	var unsupported *XattrUnsupportedError
+	if o.SkipUnsupportedXattr && errors.As(err, &unsupported) {
+		log.Printf("Warning: not archiving extended attributes: %v", err)
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+
+	if err = headerOptions(h, path, o); err != nil {
+		err = fmt.Errorf("%s: %w", path, err)
+		return
+	}
+
+	if o.StoreBirthTime {
+		err = storeBirthTime(h, unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW)
+	}
+
+	return
+}
+
+// headerOptions applies the options affecting the content of the header h of
+// the file at path once its extended attributes have been retrieved.
+func headerOptions(h *tar.Header, path string, o *Options) (err error) {
+	o.mapIDs(h)
+
+	if o.XattrProgress != nil {
+		o.XattrProgress(path, len(h.Xattrs))
+	}
+
+	if o.XattrSizeLimit > 0 {
+		for k, v := range h.Xattrs {
+			if len(v) > o.XattrSizeLimit {
+				log.Printf("Warning: extended attribute %s of %s exceeds the size limit of %d bytes.", k, path, o.XattrSizeLimit)
+			}
+		}
+	}
+
+	if len(o.PAXRecords) > 0 {
+		h.PAXRecords = make(map[string]string, len(o.PAXRecords))
+		for k, v := range o.PAXRecords {
+			h.PAXRecords[k] = v
+		}
+	}
+
+	if o.ACLPAXEncoding {
+		if err = encodeACLs(h); err != nil {
+			return
+		}
+	}
+
+	if o.PAXDeviceRecords {
+		writeDeviceRecords(h)
+	}
+
+	if err = o.XattrEncoding.encodeHeader(h); err != nil {
+		return
+	}
+
+	if o.Format != tar.FormatUnknown {
+		h.Format = o.Format
+	}
+
+	return
+}
+
+// deviceNumbers validates the device numbers of character and block device
+// entries. They are taken from the file at path if h does not carry any, e.g.
+// when the os.FileInfo did not come from a stat call.
+func deviceNumbers(h *tar.Header, path string) error {
+	if h.Typeflag != tar.TypeChar && h.Typeflag != tar.TypeBlock {
+		return nil
+	}
+
+	if h.Devmajor == 0 && h.Devminor == 0 {
+		var st unix.Stat_t
+		if err := unix.Lstat(path, &st); err != nil {
+			return err
+		}
+		h.Devmajor = int64(unix.Major(uint64(st.Rdev)))
+		h.Devminor = int64(unix.Minor(uint64(st.Rdev)))
+	}
+
+	if h.Devmajor < 0 || h.Devminor < 0 {
+		return fmt.Errorf("%s: %w", path, ErrInvalidDevice)
+	}
+
+	return nil
+}
+
+// The PAX records holding device numbers as decimal strings written with
+// WithPAXDeviceRecords.
+const (
+	paxDevMajor = "SCHILY.dev.major"
+	paxDevMinor = "SCHILY.dev.minor"
+)
+
+// writeDeviceRecords stores the device numbers of character and block device
+// entries in PAX records.
+func writeDeviceRecords(h *tar.Header) {
+	if h.Typeflag != tar.TypeChar && h.Typeflag != tar.TypeBlock {
+		return
+	}
+
+	if h.PAXRecords == nil {
+		h.PAXRecords = make(map[string]string)
+	}
+	h.PAXRecords[paxDevMajor] = strconv.FormatInt(h.Devmajor, 10)
+	h.PAXRecords[paxDevMinor] = strconv.FormatInt(h.Devminor, 10)
+}
+
+// readDeviceRecords replaces the device numbers of h with those found in its
+// PAX records.
+func readDeviceRecords(h *tar.Header) (err error) {
+	if v, ok := h.PAXRecords[paxDevMajor]; ok {
+		if h.Devmajor, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return
+		}
+	}
+
+	if v, ok := h.PAXRecords[paxDevMinor]; ok {
+		if h.Devminor, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return
+		}
+	}
+
+	if h.Devmajor < 0 || h.Devminor < 0 {
+		return ErrInvalidDevice
+	}
+
+	return
+}
+
+// WriteRawHeader writes the tar header h as is. If xattrPath is not empty the
+// extended attributes of xattrPath replace those found in h. h is not
+// modified.
+func WriteRawHeader(w *tar.Writer, h *tar.Header, xattrPath string) (err error) {
+	if xattrPath == "" {
+		return w.WriteHeader(h)
+	}
+
+	c := *h
+	c.Xattrs, err = GetAllXattr(xattrPath)
+	if err != nil {
+		return
+	}
+
+	return w.WriteHeader(&c)
+}
+
+// WriteDir writes a tar header for a directory.
+// The entry argument will become the name of the directory in the tar header.
+func WriteDir(w *tar.Writer, entry string, mode os.FileMode, uid, gid int, mtime time.Time) error {
+	if !strings.HasSuffix(entry, "/") {
+		entry = entry + "/"
+	}
+
+	return w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     entry,
+		Mode:     headerMode(mode),
+		Uid:      uid,
+		Gid:      gid,
+		ModTime:  mtime,
+	})
+}
+
+// WriteSymlink writes a tar header for a symbolic link pointing to linkTarget.
+// The entry argument will become the name of the symbolic link in the tar
+// header.
+func WriteSymlink(w *tar.Writer, entry, linkTarget string, uid, gid int, mtime time.Time) error {
+	return w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     entry,
+		Linkname: linkTarget,
+		Mode:     0777,
+		Uid:      uid,
+		Gid:      gid,
+		ModTime:  mtime,
+	})
+}
+
+// headerMode converts mode to the permission bits stored in a tar header.
+func headerMode(mode os.FileMode) int64 {
+	m := int64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= unix.S_ISUID
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= unix.S_ISGID
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= unix.S_ISVTX
+	}
+
+	return m
+}
+
+// CleanEntryName returns the name under which the file at path is stored in
+// an archive. prefix is stripped from path followed by a single leading
+// slash. Directories get a trailing slash. If nothing remains of path, e.g.
+// for the directory an archive is created from, the empty string is returned
+// and the file should not be archived.
+func CleanEntryName(f os.FileInfo, path string, prefix string) (entry string) {
+	entry = strings.TrimPrefix(path, prefix)
+	if entry == "" || entry == "/" {
+		return ""
+	}
+
+	if entry[0:1] == "/" {
+		entry = entry[1:]
+	}
+
+	if f.IsDir() && (entry[len(entry)-1:len(entry)] != "/") {
+		entry = entry + "/"
+	}
+
+	return
+}
+
+// doCreate creates a tar archive from a the directory path and strips prefix of
+// each entry. It uses filepath.Walk internally to provide deterministic input
+// in order to create e.g. content hashes of the underlying tar stream.
+func doCreate(w *tar.Writer, path string, prefix string, o *Options) error {
+	if err := writeGlobalHeader(w, o); err != nil {
+		return err
+	}
+
+	var p *progress
+	if o.Progress != nil {
+		var total int
+		err := walk(path, prefix, o, func(string, string, string, os.FileInfo) error {
+			total++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		p = newProgress(o.Progress, total)
+	}
+
+	links := make(map[fileID]string)
+	return walk(path, prefix, o, func(_ string, curpath string, entry string, f os.FileInfo) error {
+		if target, ok := hardLinkTarget(f, entry, links); ok {
+			return writeHardLink(w, curpath, entry, target, f, o, p)
+		}
+		return writeEntry(w, curpath, entry, f, o, p)
+	})
+}
+
+// fileID identifies a file by its device and inode number.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// hardLinkTarget returns the entry a regular file with multiple links was first
+// archived as. Files seen for the first time are recorded in links under entry.
+func hardLinkTarget(f os.FileInfo, entry string, links map[fileID]string) (string, bool) {
+	st, ok := f.Sys().(*syscall.Stat_t)
+	if !ok || !f.Mode().IsRegular() || st.Nlink < 2 {
+		return "", false
+	}
+
+	id := fileID{uint64(st.Dev), uint64(st.Ino)}
+	if target, ok := links[id]; ok {
+		return target, true
+	}
+	links[id] = entry
+
+	return "", false
+}
+
+// writeHardLink writes a hard link entry named entry pointing to the previously
+// archived entry target.
+func writeHardLink(w *tar.Writer, path string, entry string, target string, f os.FileInfo, o *Options, p *progress) error {
+	h, err := fileHeader(path, entry, f, o, nil)
+	if err != nil {
+		return err
+	}
+	h.Typeflag = tar.TypeLink
+	h.Linkname = target
+	h.Size = 0
+
+	if err = o.validatePath(h); err != nil {
+		return err
+	}
+
+	p.start(entry, 0)
+
+	return w.WriteHeader(h)
+}
+
+// maxSymlinkDepth is the number of nested symbolic links to directories
+// followed with WithFollowSymlinks before giving up with ErrSymlinkLoop.
+const maxSymlinkDepth = 16
+
+// walk calls fn for each file under path that is supposed to be archived.
+// The walked argument passed to fn is the path of the file below path and
+// curpath the file to archive. They only differ for symbolic links to files
+// followed with WithFollowSymlinks. The entry argument is the name of the file
+// in the archive.
+func walk(path string, prefix string, o *Options, fn func(walked string, curpath string, entry string, f os.FileInfo) error) error {
+	return walkDepth(path, prefix, o, 0, fn)
+}
+
+func walkDepth(path string, prefix string, o *Options, depth int, fn func(walked string, curpath string, entry string, f os.FileInfo) error) error {
+	return filepath.Walk(path, func(curpath string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err = o.err(); err != nil {
+			return err
+		}
+
+		if o.ExcludeHidden && curpath != path && filepath.Base(curpath)[0] == '.' {
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if len(o.ExcludeGlob) > 0 && curpath != path {
+			var excluded bool
+			if excluded, err = o.excluded(CleanEntryName(f, curpath, prefix)); err != nil {
+				return err
+			}
+			if excluded && f.IsDir() {
+				return filepath.SkipDir
+			}
+			if excluded {
+				return nil
+			}
+		}
+
+		target := curpath
+		if o.FollowSymlinks && f.Mode()&os.ModeSymlink == os.ModeSymlink {
+			f, err = os.Stat(curpath)
+			if err != nil {
+				return err
+			}
+
+			if f.IsDir() {
+				if depth >= maxSymlinkDepth {
+					return ErrSymlinkLoop
+				}
+				// A trailing slash makes filepath.Walk resolve the
+				// link while keeping its name in the paths below.
+				return walkDepth(curpath+"/", prefix, o, depth+1, fn)
+			}
+
+			target, err = filepath.EvalSymlinks(curpath)
+			if err != nil {
+				return err
+			}
+		}
+
+		s := CleanEntryName(f, curpath, prefix)
+		if s == "" {
+			return nil
+		}
+
+		if o.PathTransform != nil {
+			if s, err = transformEntryName(s, f, o); err != nil || s == "" {
+				return err
+			}
+		}
+
+		return fn(curpath, target, s, f)
+	})
+}
+
+// transformEntryName applies the function set with WithPathTransform to entry.
+// Directories keep their trailing slash. With WithContinueOnError() entries
+// the function fails for are skipped by returning the empty string.
+func transformEntryName(entry string, f os.FileInfo, o *Options) (string, error) {
+	s, err := o.PathTransform(entry)
+	if err != nil {
+		if o.ContinueOnError {
+			log.Printf("Warning: skipping %s: %v", entry, err)
+			return "", nil
+		}
+		return "", fmt.Errorf("%s: %w", entry, err)
+	}
+
+	if f.IsDir() && !strings.HasSuffix(s, "/") {
+		s += "/"
+	}
+
+	return s, nil
+}
+
+// writeEntry writes the header of the file at path under the name entry and
+// copies its content into the tar stream.
+// Regular files are opened once and described by the opened file so the header,
+// extended attributes and content cannot come from different files if path is
+// replaced concurrently.
+func writeEntry(w *tar.Writer, path string, entry string, f os.FileInfo, o *Options, p *progress) error {
+	if !f.Mode().IsRegular() {
+		if err := writeHeader(w, path, entry, f, o, nil); err != nil {
+			return err
+		}
+
+		return writeContent(w, path, entry, f, o, p)
+	}
+
+	g, err := os.OpenFile(path, os.O_RDONLY|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+
+	if f, err = g.Stat(); err != nil {
+		return err
+	}
+	if !f.Mode().IsRegular() {
+		return fmt.Errorf("%s: File changed type during archive creation.", path)
+	}
+
+	if err = writeHeader(w, path, entry, f, o, g); err != nil {
+		return err
+	}
+
+	if err = copyContent(w, g, entry, f.Size(), o, p); err != nil {
+		return err
+	}
+
+	return g.Close()
+}
+
+// writeContent copies the content of the file at path into the tar stream if
+// it is a regular file.
+func writeContent(w *tar.Writer, path string, entry string, f os.FileInfo, o *Options, p *progress) error {
+	mode := f.Mode()
+	if (mode&os.ModeSymlink == os.ModeSymlink) || (mode&os.ModeDevice == os.ModeDevice) || (mode&os.ModeNamedPipe == os.ModeNamedPipe) || f.IsDir() {
+		p.start(entry, 0)
+		return nil
+	}
+
+	g, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	if err = copyContent(w, g, entry, f.Size(), o, p); err != nil {
+		g.Close()
+		return err
+	}
+
+	return g.Close()
+}
+
+// copyContent copies the content of g holding size bytes into the tar stream.
+func copyContent(w *tar.Writer, g *os.File, entry string, size int64, o *Options, p *progress) (err error) {
+	p.start(entry, size)
+
+	var start int64
+	var r io.Reader = g
+	s := sha256.New()
+	if o.verify != nil {
+		if start, err = o.verify.Seek(0, io.SeekCurrent); err != nil {
+			return
+		}
+		r = io.TeeReader(g, s)
+	}
+
+	n, err := io.Copy(p.writer(w), r)
+	if err != nil {
+		return
+	}
+
+	if o.verify != nil {
+		return verifyWritten(o.verify, entry, start, n, s.Sum(nil))
+	}
+
+	return
+}
+
+// Extract extracts a tar archive under path.
+// Multi-volume archives are not supported. They should be reassembled into a
+// single archive before being passed to Extract.
+func Extract(archive string, path string, opts ...Option) error {
+	return extractArchiveFile(archive, path, nil, newOptions(opts))
+}
+
+// ExtractWithOptions extracts a tar archive under path configured by o. It is
+// equivalent to Extract with the corresponding Option functions. If
+// o.HashAlgorithm is not nil the uncompressed tar stream is written to it as
+// well.
+func ExtractWithOptions(archive string, path string, o Options) error {
+	return extractArchiveFile(archive, path, o.HashAlgorithm, &o)
+}
+
+// ExtractSHA256 extracts a tar archive under path and returns its SHA256-hash
+// checksum.
+// The SHA256 hash of the tar archive is created based on the tar stream and not
+// simply on the resulting archive. This is a proper content hash.
+func ExtractSHA256(archive string, path string, opts ...Option) ([]byte, error) {
+	return ExtractWithHash(archive, path, sha256.New(), opts...)
+}
+
+// ExtractFromReader extracts the tar archive read from r under path. Compressed
+// archives are decompressed transparently.
+func ExtractFromReader(r io.Reader, path string, opts ...Option) error {
+	return extractStream(r, path, nil, newOptions(opts))
+}
+
+// ExtractSHA256FromReader extracts the tar archive read from r under path and
+// returns the SHA256-hash checksum of the uncompressed tar stream.
+func ExtractSHA256FromReader(r io.Reader, path string, opts ...Option) (checksum []byte, err error) {
+	b := sha256.New()
+	if err = extractStream(r, path, b, newOptions(opts)); err != nil {
+		return
+	}
+
+	return b.Sum(nil), nil
+}
+
+// extractArchiveFile extracts a possibly compressed tar archive under path.
+// If h is not nil the uncompressed tar stream is written to it as well.
+func extractArchiveFile(archive string, path string, h io.Writer, o *Options) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if o.Progress != nil {
+		if o.totalEntries, err = countEntries(f); err != nil {
+			return err
+		}
+	}
+
+	return extractStream(f, path, h, o)
+}
+
+// extractStream extracts the possibly compressed tar stream r under path. If h
+// is not nil the uncompressed tar stream is written to it as well.
+func extractStream(r io.Reader, path string, h io.Writer, o *Options) error {
+	c, _, err := decompressReader(r, o)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	var d io.Reader = c
+	if h != nil {
+		d = io.TeeReader(c, h)
+	}
+
+	if err = doExtract(tar.NewReader(d), path, o); err != io.EOF && err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CalculateArchiveSHA256 returns the SHA256-hash checksum of an existing
+// archive file without parsing or extracting it. Unlike CreateSHA256 and
+// ExtractSHA256 this hashes the file as is which for compressed archives is
+// the compressed stream. It matches the output of sha256sum.
+func CalculateArchiveSHA256(archive string) ([]byte, error) {
+	return hashFile(archive)
+}
+
+// ExtractSHA256Verify extracts a tar archive under path and verifies that its
+// SHA256-hash checksum matches expected. A *ChecksumMismatchError is returned if
+// it does not. With WithRollbackOnMismatch() the extracted directory is removed
+// on mismatch.
+func ExtractSHA256Verify(archive string, path string, expected []byte, opts ...Option) error {
+	checksum, err := ExtractSHA256(archive, path, opts...)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(checksum, expected) {
+		return nil
+	}
+
+	if newOptions(opts).RollbackOnMismatch {
+		if err = os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	return &ChecksumMismatchError{Expected: expected, Actual: checksum}
+}
+
+// ExtractFilesOnly extracts only the regular files of a tar archive under path.
+// Directories, symbolic links and device files are skipped. The directory
+// structure is expected to exist already; if the parent directory of a file is
+// missing ErrDirectoryMissing is returned.
+func ExtractFilesOnly(archive string, path string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := tar.NewReader(f)
+
+	return doExtract(r, path, &Options{filesOnly: true})
+}
+
+// dirMeta records the metadata a directory is supposed to have once extraction
+// has finished.
+type dirMeta struct {
+	path  string
+	mode  os.FileMode
+	atime time.Time
+	mtime time.Time
+}
+
+// restoreDirs applies the recorded modes and modification times deepest
+// directory first. Extracting entries into a directory updates its
+// modification time and a read-only directory would prevent entries from being
+// extracted into it so this has to happen after all entries have been written.
+func restoreDirs(dirs []dirMeta) error {
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i].path, string(os.PathSeparator)) > strings.Count(dirs[j].path, string(os.PathSeparator))
+	})
+
+	var errs MultiError
+	for _, d := range dirs {
+		if err := os.Chmod(d.path, d.mode); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := os.Chtimes(d.path, d.atime, d.mtime); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs.err()
+}
+
+// extractor holds the state of a single extraction.
+type extractor struct {
+	path string
+	o    *Options
+	p    *progress
+	dirs []dirMeta
+
+	// seen records the entries extracted so far. It is only tracked for
+	// layer extraction where opaque whiteouts must not remove entries of
+	// the layer itself.
+	seen map[string]bool
+
+	// encoding is the encoding of extended attribute values announced by
+	// a global header of the archive. Entries can override it.
+	encoding XattrEncoding
+
+	// created is the resolved path of the entry passed to extract last if
+	// it did not exist before. It is only tracked with WithRetryOnError.
+	created string
+}
+
+// newExtractor returns an extractor extracting entries under path. Extended
+// attribute values are taken as is until a global header announces their
+// encoding.
+func newExtractor(path string, o *Options) *extractor {
+	total := -1
+	if o.totalEntries != 0 {
+		total = o.totalEntries
+	}
+
+	e := &extractor{path: path, o: o, p: newProgress(o.Progress, total), encoding: XattrEncodingRaw}
+	if o.layer {
+		e.seen = make(map[string]bool)
+	}
+
+	return e
+}
+
+func doExtract(r *tar.Reader, path string, o *Options) error {
+	var errs MultiError
+
+	if o.CreateDestinationMode != 0 {
+		if err := createDestination(path, o.CreateDestinationMode); err != nil {
+			return err
+		}
+	}
+
+	e := newExtractor(path, o)
+	for {
+		if err := o.err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		if err = e.extractRetry(h, r); err != nil {
+			errs = append(errs, fmt.Errorf("entry %q: %w", h.Name, err))
+			if !o.ContinueOnError {
+				break
+			}
+		}
+	}
+
+	if err := restoreDirs(e.dirs); err != nil {
+		errs = append(errs, err)
+	}
 
-	err = doCreate(w, path, prefix)
-	if err = w.Close(); err != nil {
-		return
+	return errs.err()
+}
+
+// extractReg extracts the regular file h. With WithVerifyEntryHash() its
+// content is compared to the hash stored in the tarski.sha256 PAX record.
+func (e *extractor) extractReg(h *tar.Header, r io.Reader) error {
+	expected, ok := h.PAXRecords[paxEntrySHA256]
+	if !e.o.VerifyEntryHash || !ok {
+		return extractReg(e.path, h, e.p.reader(r), e.o)
 	}
 
-	if err = w.Close(); err != nil {
-		return
+	s := sha256.New()
+	if err := extractReg(e.path, h, io.TeeReader(e.p.reader(r), s), e.o); err != nil {
+		return err
 	}
 
-	return
-}
+	if actual := hex.EncodeToString(s.Sum(nil)); actual != expected {
+		return &EntryHashMismatchError{Name: h.Name, Expected: expected, Actual: actual}
+	}
 
-// WriteHeader writes a tar header.
-// Deals with symbolic links and extended attributes.
-// The entry argument will become the name of the file, directory, etc. in the
-// tar header.
-func WriteHeader(w *tar.Writer, path string, entry string, f os.FileInfo) (err error) {
-	var link string
+	return nil
+}
 
-	if f.Mode()&os.ModeSymlink == os.ModeSymlink {
-		link, err = os.Readlink(path)
+// absoluteSymlink applies the AbsoluteSymlinkPolicy to the symbolic link h
+// pointing to an absolute path which is to be created at the resolved path
+// entry. It reports whether the link is to be skipped.
+func (e *extractor) absoluteSymlink(h *tar.Header, entry string) (bool, error) {
+	switch e.o.AbsoluteSymlinks {
+	case AbsoluteSymlinkError:
+		return false, fmt.Errorf("%s: %w", h.Linkname, ErrAbsoluteSymlink)
+	case AbsoluteSymlinkSkip:
+		return true, nil
+	case AbsoluteSymlinkRelativize:
+		rel, err := rootedSymlink(filepath.Clean(e.path), filepath.Dir(entry), h.Linkname)
 		if err != nil {
-			return
+			return false, err
 		}
+		h.Linkname = rel
 	}
 
-	h, err := tar.FileInfoHeader(f, link)
-	if err != nil {
-		return
-	}
+	return false, nil
+}
 
-	h.Name = entry
-	h.Xattrs, err = GetAllXattr(path)
-	if err != nil {
-		return
+// pathDepth returns the number of non-empty components of the entry name.
+func pathDepth(name string) (depth int) {
+	for _, c := range strings.Split(name, "/") {
+		if c != "" && c != "." {
+			depth++
+		}
 	}
 
-	return w.WriteHeader(h)
+	return
 }
 
-func cleanEntry(f os.FileInfo, path string, prefix string) (entry string) {
-	entry = strings.TrimPrefix(path, prefix)
-	if entry == "" || entry == "/" {
-		return
+// stripComponents removes the first n non-empty components of the entry name.
+// It reports false if no components are left.
+func stripComponents(name string, n int) (string, bool) {
+	var components []string
+	for _, c := range strings.Split(name, "/") {
+		if c != "" && c != "." {
+			components = append(components, c)
+		}
+	}
+	if len(components) <= n {
+		return "", false
 	}
 
-	if entry[0:1] == "/" {
-		entry = entry[1:]
+	stripped := strings.Join(components[n:], "/")
+	if strings.HasSuffix(name, "/") {
+		stripped += "/"
 	}
 
-	if f.IsDir() && (entry[len(entry)-1:len(entry)] != "/") {
-		entry = entry + "/"
+	return stripped, true
+}
+
+// createDestination creates the directory path with mode if it does not
+// exist.
+func createDestination(path string, mode os.FileMode) error {
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return err
 	}
 
-	return
+	if err := os.MkdirAll(path, mode); err != nil {
+		return err
+	}
+
+	return os.Chmod(path, mode)
 }
 
-// doCreate creates a tar archive from a the directory path and strips prefix of
-// each entry. It uses filepath.Walk internally to provide deterministic input
-// in order to create e.g. content hashes of the underlying tar stream.
-func doCreate(w *tar.Writer, path string, prefix string) error {
-	return filepath.Walk(path, func(curpath string, f os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// extract extracts the entry described by h.
+func (e *extractor) extract(h *tar.Header, r io.Reader) (err error) {
+	if h.Typeflag == tar.TypeXGlobalHeader {
+		// Global headers only carry metadata.
+		if enc, ok := h.PAXRecords[paxXattrEncoding]; ok {
+			e.encoding, err = parseXattrEncoding(enc)
 		}
+		return err
+	}
 
-		s := cleanEntry(f, curpath, prefix)
-		if s == "" {
+	if n := e.o.StripComponents; n > 0 {
+		var ok bool
+		if h.Name, ok = stripComponents(h.Name, n); !ok {
 			return nil
 		}
-
-		mode := f.Mode()
-		if err := WriteHeader(w, curpath, s, f); err != nil {
-			return err
+		if h.Typeflag == tar.TypeLink {
+			if h.Linkname, ok = stripComponents(h.Linkname, n); !ok {
+				return nil
+			}
 		}
+	}
+	e.o.mapIDs(h)
 
-		if (mode&os.ModeSymlink == os.ModeSymlink) || (mode&os.ModeDevice == os.ModeDevice) || f.IsDir() {
-			return nil
-		}
+	if max := e.o.maxPathDepth(); max > 0 && pathDepth(h.Name) > max {
+		return ErrPathTooDeep
+	}
 
-		g, err := os.Open(curpath)
-		if err != nil {
+	if e.o.filesOnly && h.Typeflag != tar.TypeReg {
+		return nil
+	}
+
+	// The extraction functions check this as well but it needs to happen
+	// before anything is removed.
+	entry, err := sanitizePath(e.path, h.Name)
+	if err != nil {
+		return err
+	}
+	if e.o.RetryMax > 0 {
+		if _, lerr := os.Lstat(entry); os.IsNotExist(lerr) {
+			e.created = entry
+		}
+	}
+	if h.Typeflag == tar.TypeLink {
+		if _, err = sanitizePath(e.path, h.Linkname); err != nil {
 			return err
 		}
+	}
 
-		if _, err = io.Copy(w, g); err != nil {
+	if e.o.layer {
+		var ok bool
+		if ok, err = e.whiteout(h); ok || err != nil {
 			return err
 		}
+	}
 
-		if err = g.Close(); err != nil {
+	if h.Typeflag == tar.TypeSymlink && filepath.IsAbs(h.Linkname) {
+		var skip bool
+		if skip, err = e.absoluteSymlink(h, entry); skip || err != nil {
 			return err
 		}
+	}
 
-		return nil
-	})
-}
+	e.p.start(h.Name, h.Size)
 
-// Extract extracts a tar archive under path.
-func Extract(archive string, path string) error {
-	f, err := os.Open(archive)
-	if err != nil {
+	enc := e.encoding
+	if s, ok := h.PAXRecords[paxXattrEncoding]; ok {
+		if enc, err = parseXattrEncoding(s); err != nil {
+			return err
+		}
+	}
+	if h.Xattrs, err = enc.decode(h.Xattrs); err != nil {
 		return err
 	}
-	defer f.Close()
 
-	r := tar.NewReader(f)
+	if err = decodeACLs(h); err != nil {
+		return err
+	}
+
+	if e.o.Overwrite == OverwriteReplace {
+		if err = removeExisting(entry, h); err != nil {
+			return err
+		}
+	}
 
-	if err = doExtract(r, path); err != io.EOF && err != nil {
+	switch h.Typeflag {
+	case tar.TypeDir, typeGNUDumpDir:
+		// The listing of dump directories is only needed to restore
+		// incremental backups. See ReadGNUIncremental.
+		var dir string
+		if dir, err = extractDir(e.path, h, e.o); err == nil {
+			fi := h.FileInfo()
+			e.dirs = append(e.dirs, dirMeta{dir, e.o.fileMode(fi.Mode()), accessTime(h), fi.ModTime()})
+		}
+	case tar.TypeSymlink:
+		err = extractSymlink(e.path, h, e.o)
+	case tar.TypeLink:
+		err = extractHardLink(e.path, h, e.o)
+	case tar.TypeChar, tar.TypeBlock:
+		if e.o.PAXDeviceRecords {
+			if err = readDeviceRecords(h); err != nil {
+				return err
+			}
+		}
+		err = extractDev(e.path, h, e.o)
+	case tar.TypeFifo:
+		err = extractFIFO(e.path, h, e.o)
+	case tar.TypeGNUSparse:
+		err = e.extractReg(h, r)
+	case typeGNUVolHeader:
+		if !e.o.SkipVolumeHeaders {
+			err = ErrMultiVolumeArchive
+		}
 		return err
+	default:
+		err = e.extractReg(h, r)
 	}
 
-	return nil
+	if err == nil && e.seen != nil {
+		e.seen[filepath.Clean(h.Name)] = true
+	}
+
+	return err
 }
 
-// ExtractSHA256 extracts a tar archive under path and returns its SHA256-hash
-// checksum.
-// The SHA256 hash of the tar archive is created based on the tar stream and not
-// simply on the resulting archive. This is a proper content hash.
-func ExtractSHA256(archive string, path string) (checksum []byte, err error) {
-	a, err := os.Open(archive)
+// removeExisting removes whatever exists at entry unless both the existing
+// file and the entry described by h are directories.
+func removeExisting(entry string, h *tar.Header) error {
+	fi, err := os.Lstat(entry)
+	if os.IsNotExist(err) {
+		return nil
+	}
 	if err != nil {
-		return
+		return err
 	}
-	defer a.Close()
-
-	b := sha256.New()
-	c := io.TeeReader(a, b)
-	d := tar.NewReader(c)
 
-	if err = doExtract(d, path); err != io.EOF && err != nil {
-		return
+	if fi.IsDir() && h.Typeflag == tar.TypeDir {
+		return nil
 	}
 
-	return b.Sum(nil), nil
+	return os.RemoveAll(entry)
 }
 
-func doExtract(r *tar.Reader, path string) (err error) {
-	for h, err := r.Next(); err != io.EOF; h, err = r.Next() {
-		if err != nil {
-			break
-		}
-
-		if h.Typeflag == tar.TypeDir {
-			if err := ExtractDir(path, h); err != nil {
-				return err
-			}
-		} else if h.Typeflag == tar.TypeSymlink {
-			if err := ExtractSymlink(path, h); err != nil {
-				return err
-			}
-		} else if h.Typeflag == tar.TypeChar || h.Typeflag == tar.TypeBlock {
-			if err := ExtractDev(path, h); err != nil {
-				return err
-			}
-		} else {
-			if err := ExtractReg(path, h, r); err != nil {
-				return err
-			}
-		}
+// accessTime returns the access time recorded in h. Only the PAX and GNU
+// formats record access times so the modification time is used if there is
+// none.
+func accessTime(h *tar.Header) time.Time {
+	if h.AccessTime.IsZero() {
+		return h.ModTime
 	}
 
-	return err
+	return h.AccessTime
 }
 
 // ExtractDir extracts a directory from a tar archive.
 func ExtractDir(path string, h *tar.Header) (err error) {
-	entry := filepath.Join(path, h.Name)
-	fi := h.FileInfo()
+	entry, err := extractDir(path, h, &Options{})
+	if err != nil {
+		return
+	}
+
+	return restoreDirs([]dirMeta{{entry, h.FileInfo().Mode(), accessTime(h), h.FileInfo().ModTime()}})
+}
+
+// extractDir extracts a directory and returns its path. An existing symbolic
+// link in its place is followed as long as it stays below path. The directory
+// is left writable and searchable by its owner so its entries can be extracted
+// into it. Its exact mode and times are applied by restoreDirs.
+func extractDir(path string, h *tar.Header, o *Options) (entry string, err error) {
+	if entry, err = sanitizePath(path, h.Name); err != nil {
+		return
+	}
+	if entry, err = resolveBeneath(filepath.Clean(path), entry); err != nil {
+		err = fmt.Errorf("%s: %w", h.Name, err)
+		return
+	}
+	mode := o.fileMode(h.FileInfo().Mode()) | 0700
 
-	err = os.MkdirAll(entry, fi.Mode())
+	err = os.MkdirAll(entry, mode)
 	if err != nil {
 		return
 	}
@@ -256,13 +1317,19 @@ func ExtractDir(path string, h *tar.Header) (err error) {
 		return
 	}
 
-	for attr, data := range h.Xattrs {
-		if err = unix.Setxattr(entry, attr, []byte(data), 0); err != nil {
+	if o.Overwrite == OverwriteReplace && o.CleanXattrsOnOverwrite {
+		if err = RemoveAllXattr(entry); err != nil {
 			return
 		}
 	}
 
-	if err = os.Chtimes(entry, time.Now(), fi.ModTime()); err != nil {
+	if err = setXattrs(entry, h.Xattrs, o); err != nil {
+		return
+	}
+
+	// os.MkdirAll() is subject to the umask and leaves existing directories
+	// alone.
+	if err = os.Chmod(entry, mode); err != nil {
 		return
 	}
 
@@ -271,21 +1338,43 @@ func ExtractDir(path string, h *tar.Header) (err error) {
 
 // ExtractReg extracts a regular file from a tar archive.
 func ExtractReg(path string, h *tar.Header, r *tar.Reader) (err error) {
+	return extractReg(path, h, r, &Options{})
+}
+
+// openFile creates extracted regular files. It is replaced in tests.
+var openFile = os.OpenFile
+
+func extractReg(path string, h *tar.Header, r io.Reader, o *Options) (err error) {
 	fi := h.FileInfo()
-	entry := filepath.Join(path, h.Name)
+	mode := o.fileMode(fi.Mode())
+	entry, err := sanitizePath(path, h.Name)
+	if err != nil {
+		return
+	}
 	filedir := filepath.Join(path, filepath.Dir(h.Name))
 
-	err = os.MkdirAll(filedir, fi.Mode())
+	if o.filesOnly {
+		if _, err = os.Stat(filedir); os.IsNotExist(err) {
+			return fmt.Errorf("%s: %w", filedir, ErrDirectoryMissing)
+		}
+	} else {
+		err = os.MkdirAll(filedir, mode)
+	}
 	if err != nil {
 		return
 	}
 
-	g, err := os.OpenFile(entry, os.O_EXCL|os.O_WRONLY|os.O_CREATE, fi.Mode())
+	g, err := openFile(entry, os.O_EXCL|os.O_WRONLY|os.O_CREATE, mode)
 	if err != nil {
 		return
 	}
 
-	w, err := io.Copy(g, r)
+	var w int64
+	if h.Typeflag == tar.TypeGNUSparse {
+		w, err = copySparse(g, r)
+	} else {
+		w, err = io.Copy(g, r)
+	}
 	if err != nil {
 		return
 	}
@@ -296,6 +1385,13 @@ func ExtractReg(path string, h *tar.Header, r *tar.Reader) (err error) {
 		return
 	}
 
+	if o.sync {
+		if err = fsync(int(g.Fd())); err != nil {
+			g.Close()
+			return
+		}
+	}
+
 	if err = g.Close(); err != nil {
 		return
 	}
@@ -304,26 +1400,112 @@ func ExtractReg(path string, h *tar.Header, r *tar.Reader) (err error) {
 		return err
 	}
 
-	for attr, data := range h.Xattrs {
-		if err = unix.Setxattr(entry, attr, []byte(data), 0); err != nil {
+	if o.Overwrite == OverwriteReplace && o.CleanXattrsOnOverwrite {
+		if err = RemoveAllXattr(entry); err != nil {
 			return err
 		}
 	}
 
-	if err = os.Chtimes(entry, fi.ModTime(), fi.ModTime()); err != nil {
+	if err = setXattrs(entry, h.Xattrs, o); err != nil {
+		return err
+	}
+
+	if err = os.Chtimes(entry, accessTime(h), fi.ModTime()); err != nil {
+		return err
+	}
+
+	// os.Chown() clears the setuid and setgid bits so restore the exact
+	// mode last.
+	if err = os.Chmod(entry, mode); err != nil {
 		return err
 	}
 
 	return
 }
 
+// copySparse copies r to f seeking over blocks of zeros instead of writing them
+// so holes of sparse files are recreated. The file is truncated to the number
+// of bytes copied so trailing holes are preserved as well.
+func copySparse(f *os.File, r io.Reader) (n int64, err error) {
+	buf := make([]byte, 8*blockSize)
+	zero := make([]byte, len(buf))
+
+	for {
+		m, rerr := io.ReadFull(r, buf)
+		if m > 0 {
+			if bytes.Equal(buf[:m], zero[:m]) {
+				_, err = f.Seek(int64(m), io.SeekCurrent)
+			} else {
+				_, err = f.Write(buf[:m])
+			}
+			if err != nil {
+				return
+			}
+			n += int64(m)
+		}
+
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return n, rerr
+		}
+	}
+
+	return n, f.Truncate(n)
+}
+
+// ExtractHardLink extracts a hard link from a tar archive. The target of the
+// hard link must already have been extracted under path.
+func ExtractHardLink(path string, h *tar.Header) (err error) {
+	return extractHardLink(path, h, &Options{})
+}
+
+func extractHardLink(path string, h *tar.Header, o *Options) (err error) {
+	fi := h.FileInfo()
+	entry, err := sanitizePath(path, h.Name)
+	if err != nil {
+		return
+	}
+	target, err := sanitizePath(path, h.Linkname)
+	if err != nil {
+		return
+	}
+	filedir := filepath.Join(path, filepath.Dir(h.Name))
+
+	err = os.MkdirAll(filedir, o.fileMode(fi.Mode()))
+	if err != nil {
+		return
+	}
+
+	return os.Link(target, entry)
+}
+
 // ExtractSymlink extracts a symbolic link from a tar archive.
 func ExtractSymlink(path string, h *tar.Header) (err error) {
+	return extractSymlink(path, h, &Options{})
+}
+
+func extractSymlink(path string, h *tar.Header, o *Options) (err error) {
 	fi := h.FileInfo()
-	entry := filepath.Join(path, h.Name)
+	entry, err := sanitizePath(path, h.Name)
+	if err != nil {
+		return
+	}
+
+	base, dir := filepath.Clean(path), filepath.Dir(entry)
+	if o.UnsafeSymlinks != UnsafeSymlinkAllow && symlinkEscapes(base, dir, h.Linkname) {
+		if o.UnsafeSymlinks == UnsafeSymlinkError {
+			return fmt.Errorf("%s -> %s: %w", h.Name, h.Linkname, ErrUnsafeSymlink)
+		}
+		if h.Linkname, err = rootedSymlink(base, dir, h.Linkname); err != nil {
+			return
+		}
+	}
+
 	filedir := filepath.Join(path, filepath.Dir(h.Name))
 
-	err = os.MkdirAll(filedir, fi.Mode())
+	err = os.MkdirAll(filedir, o.fileMode(fi.Mode()))
 	if err != nil {
 		return
 	}
@@ -337,8 +1519,8 @@ func ExtractSymlink(path string, h *tar.Header) (err error) {
 	}
 
 	var times = make([]unix.Timespec, 2)
-	times[0].Sec = time.Now().Unix()
-	times[1].Sec = fi.ModTime().Unix()
+	times[0] = unix.NsecToTimespec(accessTime(h).UnixNano())
+	times[1] = unix.NsecToTimespec(fi.ModTime().UnixNano())
 	err = unix.UtimesNanoAt(unix.AT_FDCWD, entry, times, unix.AT_SYMLINK_NOFOLLOW)
 	if err != nil {
 		return err
@@ -347,23 +1529,61 @@ func ExtractSymlink(path string, h *tar.Header) (err error) {
 	return
 }
 
+// symlinkEscapes reports whether a symbolic link created in the resolved
+// directory dir points outside of base.
+func symlinkEscapes(base string, dir string, link string) bool {
+	target := filepath.Clean(link)
+	if !filepath.IsAbs(link) {
+		target = filepath.Join(dir, link)
+	}
+
+	return !isBeneath(base, target)
+}
+
+// rootedSymlink returns the target of a symbolic link created in the resolved
+// directory dir as if base was the root directory, relative to dir.
+func rootedSymlink(base string, dir string, link string) (string, error) {
+	rel, err := filepath.Rel(base, dir)
+	if err != nil {
+		return "", err
+	}
+
+	// Joining to the root directory drops leading ".." components so the
+	// result cannot escape it.
+	target := filepath.Join("/", link)
+	if !filepath.IsAbs(link) {
+		target = filepath.Join("/", rel, link)
+	}
+
+	return filepath.Rel(dir, filepath.Join(base, target))
+}
+
 // ExtractDev extracts a device file from a tar archive.
 func ExtractDev(path string, h *tar.Header) (err error) {
-	fi := h.FileInfo()
-	entry := filepath.Join(path, h.Name)
-	filedir := filepath.Join(path, filepath.Dir(h.Name))
+	return extractDev(path, h, &Options{})
+}
 
-	err = os.MkdirAll(filedir, fi.Mode())
+func extractDev(path string, h *tar.Header, o *Options) (err error) {
+	fi := h.FileInfo()
+	perm := o.fileMode(fi.Mode()).Perm()
+	entry, err := sanitizePath(path, h.Name)
 	if err != nil {
 		return
 	}
+	filedir := filepath.Join(path, filepath.Dir(h.Name))
 
-	g, err := os.OpenFile(entry, os.O_EXCL|os.O_WRONLY|os.O_CREATE, fi.Mode())
+	err = os.MkdirAll(filedir, o.fileMode(fi.Mode()))
 	if err != nil {
 		return
 	}
 
-	if err = g.Close(); err != nil {
+	mode := uint32(perm) | unix.S_IFCHR
+	if h.Typeflag == tar.TypeBlock {
+		mode = uint32(perm) | unix.S_IFBLK
+	}
+
+	dev := unix.Mkdev(uint32(h.Devmajor), uint32(h.Devminor))
+	if err = unix.Mknod(entry, mode, int(dev)); err != nil {
 		return
 	}
 
@@ -371,96 +1591,52 @@ func ExtractDev(path string, h *tar.Header) (err error) {
 		return
 	}
 
-	if err = os.Chtimes(entry, fi.ModTime(), fi.ModTime()); err != nil {
+	// The process umask applies to mknod.
+	if err = os.Chmod(entry, perm); err != nil {
+		return
+	}
+
+	if err = os.Chtimes(entry, accessTime(h), fi.ModTime()); err != nil {
 		return err
 	}
 
 	return
 }
 
-// This uses ssize_t llistxattr(const char *path, char *list, size_t size); to
-// handle symbolic links (should it in the future be possible to set extended
-// attributed on symlinks): If path is a symbolic link the extended attributes
-// associated with the link itself are retrieved.
-func llistxattr(path string, list []byte) (sz int, err error) {
-	var _p0 *byte
-	_p0, err = unix.BytePtrFromString(path)
+// ExtractFIFO extracts a named pipe from a tar archive.
+func ExtractFIFO(path string, h *tar.Header) (err error) {
+	return extractFIFO(path, h, &Options{})
+}
+
+func extractFIFO(path string, h *tar.Header, o *Options) (err error) {
+	fi := h.FileInfo()
+	perm := o.fileMode(fi.Mode()).Perm()
+	entry, err := sanitizePath(path, h.Name)
 	if err != nil {
 		return
 	}
-	var _p1 unsafe.Pointer
-	if len(list) > 0 {
-		_p1 = unsafe.Pointer(&list[0])
-	} else {
-		_p1 = unsafe.Pointer(nil)
-	}
-	r0, _, e1 := unix.Syscall(unix.SYS_LLISTXATTR, uintptr(unsafe.Pointer(_p0)), uintptr(_p1), uintptr(len(list)))
-	sz = int(r0)
-	if e1 != 0 {
-		err = e1
-	}
-	return
-}
-
-// GetAllXattr retrieves all extended attributes associated with a file,
-// directory or symbolic link.
-func GetAllXattr(path string) (xattrs map[string]string, err error) {
-	e1 := errors.New("Extended attributes changed during retrieval.")
+	filedir := filepath.Join(path, filepath.Dir(h.Name))
 
-	pre, err := llistxattr(path, nil)
-	if err != nil || pre < 0 {
-		return nil, err
-	}
-	if pre == 0 {
-		return nil, nil
+	err = os.MkdirAll(filedir, o.fileMode(fi.Mode()))
+	if err != nil {
+		return
 	}
 
-	dest := make([]byte, pre)
-
-	post, err := llistxattr(path, dest)
-	if err != nil || post < 0 {
-		return nil, err
-	}
-	if post != pre {
-		return nil, e1
+	if err = unix.Mkfifo(entry, uint32(perm)); err != nil {
+		return
 	}
 
-	split := strings.Split(string(dest), "\x00")
-	if split == nil {
-		return nil, errors.New("No valid extended attribute key found.")
-	}
-	// *listxattr functions return a list of  names  as  an unordered array
-	// of null-terminated character strings (attribute names are separated
-	// by null bytes ('\0')), like this: user.name1\0system.name1\0user.name2\0
-	// Since we split at the '\0'-byte the last element of the slice will be
-	// the empty string. We remove it:
-	if split[len(split)-1] == "" {
-		split = split[:len(split)-1]
+	if err = os.Chown(entry, h.Uid, h.Gid); err != nil {
+		return
 	}
 
-	xattrs = make(map[string]string, len(split))
-
-	for _, x := range split {
-		xattr := string(x)
-		pre, err = unix.Getxattr(path, xattr, nil)
-		if err != nil || pre < 0 {
-			return nil, err
-		}
-		if pre == 0 {
-			return nil, errors.New("No valid extended attribute value found.")
-		}
-
-		dest = make([]byte, pre)
-		post, err = unix.Getxattr(path, xattr, dest)
-		if err != nil || post < 0 {
-			return nil, err
-		}
-		if post != pre {
-			return nil, e1
-		}
+	if err = os.Chmod(entry, perm); err != nil {
+		return
+	}
 
-		xattrs[xattr] = string(dest)
+	if err = os.Chtimes(entry, accessTime(h), fi.ModTime()); err != nil {
+		return err
 	}
 
-	return xattrs, nil
+	return
 }
@@ -2,13 +2,23 @@ package tarski
 
 import (
 	"archive/tar"
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"golang.org/x/sys/unix"
 	"io"
 	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 const archive string = "test.tar"
@@ -312,3 +322,1415 @@ func TestExtractSHA256(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+type testEntry struct {
+	header  *tar.Header
+	content string
+}
+
+// writeTestArchive writes a synthetic tar archive made up of entries to a
+// temporary file and returns its path.
+func writeTestArchive(t *testing.T, entries []testEntry) string {
+	f, err := os.CreateTemp(t.TempDir(), "tarski-*.tar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := tar.NewWriter(f)
+	for _, e := range entries {
+		if e.header.Typeflag == tar.TypeReg {
+			e.header.Size = int64(len(e.content))
+		}
+		if err = w.WriteHeader(e.header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.WriteString(w, e.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return f.Name()
+}
+
+func TestExtractRestoresDirTimes(t *testing.T) {
+	mtime := time.Date(2001, time.February, 3, 4, 5, 6, 0, time.UTC)
+	archive := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "a/", Mode: 0755, ModTime: mtime}},
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "a/b/", Mode: 0755, ModTime: mtime}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "a/b/file", Mode: 0644, ModTime: mtime}, content: "content"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "a/file", Mode: 0644, ModTime: mtime}, content: "content"},
+	})
+
+	dest := t.TempDir()
+	if err := Extract(archive, dest, WithContinueOnError()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dir := range []string{"a", "a/b"} {
+		fi, err := os.Stat(filepath.Join(dest, dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.ModTime().Equal(mtime) {
+			t.Fatalf("Expected directory %s to have modification time %s. Found %s instead.", dir, mtime, fi.ModTime())
+		}
+	}
+}
+
+func TestExtractReadOnlyDir(t *testing.T) {
+	archive := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "ro/", Mode: 0555}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "ro/file", Mode: 0644}, content: "content"},
+	})
+
+	dest := t.TempDir()
+	var during os.FileMode
+	err := Extract(archive, dest, WithProgress(func(info ProgressInfo) {
+		if info.Entry == "ro/file" && during == 0 {
+			if fi, err := os.Stat(filepath.Join(dest, "ro")); err == nil {
+				during = fi.Mode().Perm()
+			}
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if during&0700 != 0700 {
+		t.Fatalf("Expected ro to be writable by its owner during extraction. Found %o instead.", during)
+	}
+
+	fi, err := os.Stat(filepath.Join(dest, "ro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0555 {
+		t.Fatalf("Expected ro to have mode 0555 after extraction. Found %o instead.", fi.Mode().Perm())
+	}
+	if err = os.Chmod(filepath.Join(dest, "ro"), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractFilesOnly(t *testing.T) {
+	archive := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "etc/", Mode: 0700}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "etc/config", Mode: 0644}, content: "key=value"},
+		{header: &tar.Header{Typeflag: tar.TypeSymlink, Name: "etc/link", Linkname: "config"}},
+	})
+
+	dest := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dest, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExtractFilesOnly(archive, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dest, "etc/config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "key=value" {
+		t.Fatalf("Expected file content %q. Received %q instead.", "key=value", string(b))
+	}
+
+	if _, err = os.Lstat(filepath.Join(dest, "etc/link")); !os.IsNotExist(err) {
+		t.Fatalf("Expected symbolic link to be skipped.")
+	}
+
+	fi, err := os.Stat(filepath.Join(dest, "etc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0755 {
+		t.Fatalf("Expected existing directory to keep mode 0755. Found %o instead.", fi.Mode().Perm())
+	}
+
+	if err = ExtractFilesOnly(archive, t.TempDir()); !errors.Is(err, ErrDirectoryMissing) {
+		t.Fatalf("Expected ErrDirectoryMissing. Received %v instead.", err)
+	}
+}
+
+func TestExtractPreservesSpecialBits(t *testing.T) {
+	src := t.TempDir()
+	modes := map[string]os.FileMode{
+		"setuid": 0755 | os.ModeSetuid,
+		"setgid": 0755 | os.ModeSetgid,
+		"sticky": 0777 | os.ModeSticky | os.ModeDir,
+	}
+
+	for name, mode := range modes {
+		p := filepath.Join(src, name)
+		if mode.IsDir() {
+			if err := os.Mkdir(p, 0755); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := os.WriteFile(p, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	if err := Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, mode := range modes {
+		fi, err := os.Stat(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode() != mode {
+			t.Fatalf("Expected %s to have mode %s. Found %s instead.", name, mode, fi.Mode())
+		}
+	}
+
+	fi, err := os.Stat(filepath.Join(dest, "setuid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSetuid == 0 {
+		t.Fatalf("Expected setuid bit to be preserved.")
+	}
+}
+
+func TestExtractVolumeHeader(t *testing.T) {
+	archive := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: typeGNUVolHeader, Name: "volume 2", Format: tar.FormatGNU}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "file", Mode: 0644}, content: "content"},
+	})
+
+	if err := Extract(archive, t.TempDir()); !errors.Is(err, ErrMultiVolumeArchive) {
+		t.Fatalf("Expected ErrMultiVolumeArchive. Received %v instead.", err)
+	}
+
+	dest := t.TempDir()
+	if err := Extract(archive, dest, WithSkipVolumeHeaders()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "volume 2")); !os.IsNotExist(err) {
+		t.Fatalf("Expected volume header not to be extracted.")
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "file")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteDirAndSymlink(t *testing.T) {
+	mtime := time.Date(2010, time.March, 4, 5, 6, 7, 0, time.UTC)
+
+	f, err := os.Create(filepath.Join(t.TempDir(), archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := tar.NewWriter(f)
+	if err = WriteDir(w, "dir", 0750|os.ModeSetgid, 1000, 1001, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteSymlink(w, "dir/link", "../target", 1002, 1003, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	r := tar.NewReader(f)
+	h, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Typeflag != tar.TypeDir || h.Name != "dir/" || h.Mode != 02750 || h.Uid != 1000 || h.Gid != 1001 || !h.ModTime.Equal(mtime) {
+		t.Fatalf("Unexpected directory header %+v.", h)
+	}
+
+	h, err = r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Typeflag != tar.TypeSymlink || h.Name != "dir/link" || h.Linkname != "../target" || h.Uid != 1002 || h.Gid != 1003 || !h.ModTime.Equal(mtime) {
+		t.Fatalf("Unexpected symbolic link header %+v.", h)
+	}
+
+	if _, err = r.Next(); err != io.EOF {
+		t.Fatalf("Expected end of archive. Received %v instead.", err)
+	}
+}
+
+func TestExtractSHA256Verify(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	checksum, err := CreateSHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = ExtractSHA256Verify(tarball, filepath.Join(t.TempDir(), "good"), checksum); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the content of "Dir/somefile" while leaving the headers
+	// intact.
+	b, err := os.ReadFile(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(b, []byte("This is a regular file."))
+	if i < 0 {
+		t.Fatalf("Expected to find the content of Dir/somefile in the archive.")
+	}
+	b[i] = 'X'
+	if err = os.WriteFile(tarball, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "bad")
+	err = ExtractSHA256Verify(tarball, dest, checksum, WithRollbackOnMismatch())
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected ChecksumMismatchError. Received %v instead.", err)
+	}
+	if !bytes.Equal(mismatch.Expected, checksum) {
+		t.Fatalf("Expected checksum %x to be reported. Received %x instead.", checksum, mismatch.Expected)
+	}
+
+	if _, err = os.Stat(dest); !os.IsNotExist(err) {
+		t.Fatalf("Expected %s to be removed after checksum mismatch.", dest)
+	}
+}
+
+func TestExtractErrorReportsEntry(t *testing.T) {
+	archive := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "first", Mode: 0644}, content: "content"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "conflict", Mode: 0644}, content: "content"},
+	})
+
+	dest := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dest, "conflict"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Extract(archive, dest)
+	if err == nil {
+		t.Fatalf("Expected extraction to fail.")
+	}
+	if !strings.Contains(err.Error(), `"conflict"`) {
+		t.Fatalf("Expected error to name the entry \"conflict\". Received %v instead.", err)
+	}
+	if !errors.Is(err, os.ErrExist) {
+		t.Fatalf("Expected error to wrap os.ErrExist. Received %v instead.", err)
+	}
+}
+
+// readEntryNames returns the names of all entries of an archive in order.
+func readEntryNames(t *testing.T, archive string) []string {
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var names []string
+	r := tar.NewReader(f)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, h.Name)
+	}
+
+	return names
+}
+
+func TestCreateExcludeHidden(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{".hidden", ".hiddendir/file", "visible", "dir/.hidden", "dir/file"} {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src, WithExcludeHidden()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"dir/", "dir/file", "visible"}
+	names := readEntryNames(t, tarball)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Found %v instead.", expected, names)
+	}
+}
+
+func TestWriteRawHeader(t *testing.T) {
+	src := filepath.Join(t.TempDir(), archive)
+	if err := Create(src, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var b bytes.Buffer
+	w := tar.NewWriter(&b)
+
+	var headers []*tar.Header
+	r := tar.NewReader(f)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers = append(headers, h)
+
+		if err = WriteRawHeader(w, h, ""); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.Copy(w, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r = tar.NewReader(&b)
+	for i := 0; ; i++ {
+		h, err := r.Next()
+		if err == io.EOF {
+			if i != len(headers) {
+				t.Fatalf("Expected %d entries. Found %d instead.", len(headers), i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(h, headers[i]) {
+			t.Fatalf("Expected header %+v. Found %+v instead.", headers[i], h)
+		}
+	}
+
+	// Extended attributes are read from xattrPath when given.
+	b.Reset()
+	w = tar.NewWriter(&b)
+	if err = WriteRawHeader(w, &tar.Header{Typeflag: tar.TypeReg, Name: "copy", Mode: 0644}, prefix+entries[6]); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	h, err := tar.NewReader(&b).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(h.Xattrs, testxattr) {
+		t.Fatalf("Expected extended attributes %v. Found %v instead.", testxattr, h.Xattrs)
+	}
+}
+
+func TestResetArchive(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	empty, err := IsEmpty(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty {
+		t.Fatalf("Expected %s not to be empty.", tarball)
+	}
+
+	if err = ResetArchive(tarball); err != nil {
+		t.Fatal(err)
+	}
+
+	empty, err = IsEmpty(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !empty {
+		t.Fatalf("Expected %s to be empty after reset.", tarball)
+	}
+
+	fi, err := os.Stat(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 2*blockSize {
+		t.Fatalf("Expected %s to hold only the end-of-archive marker. Found %d bytes.", tarball, fi.Size())
+	}
+}
+
+func TestExtractAccessTime(t *testing.T) {
+	mtime := time.Date(2005, time.June, 7, 8, 9, 10, 0, time.UTC)
+	atime := time.Date(2006, time.July, 8, 9, 10, 11, 0, time.UTC)
+	archive := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755, ModTime: mtime, AccessTime: atime, Format: tar.FormatPAX}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "dir/file", Mode: 0644, ModTime: mtime, AccessTime: atime, Format: tar.FormatPAX}, content: "content"},
+		{header: &tar.Header{Typeflag: tar.TypeSymlink, Name: "dir/link", Linkname: "file", ModTime: mtime, AccessTime: atime, Format: tar.FormatPAX}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "noatime", Mode: 0644, ModTime: mtime}, content: "content"},
+	})
+
+	dest := t.TempDir()
+	if err := Extract(archive, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]time.Time{
+		"dir":      atime,
+		"dir/file": atime,
+		"dir/link": atime,
+		"noatime":  mtime,
+	}
+	for name, want := range expected {
+		fi, err := os.Lstat(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		st := fi.Sys().(*syscall.Stat_t)
+		found := time.Unix(st.Atim.Sec, st.Atim.Nsec)
+		if !found.Equal(want) {
+			t.Fatalf("Expected %s to have access time %s. Found %s instead.", name, want, found)
+		}
+	}
+}
+
+func TestExtractChmodUmask(t *testing.T) {
+	archive := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0777}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "dir/file", Mode: 0777}, content: "content"},
+		{header: &tar.Header{Typeflag: tar.TypeFifo, Name: "dir/fifo", Mode: 0777}},
+		{header: &tar.Header{Typeflag: tar.TypeChar, Name: "dir/null", Mode: 0777, Devmajor: 1, Devminor: 3}},
+	})
+
+	dest := t.TempDir()
+	if err := Extract(archive, dest, WithChmodUmask(0022)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"dir", "dir/file", "dir/fifo", "dir/null"} {
+		fi, err := os.Stat(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0755 {
+			t.Fatalf("Expected %s to have mode 0755. Found %o instead.", name, fi.Mode().Perm())
+		}
+	}
+}
+
+func TestExtractMaxPermissions(t *testing.T) {
+	archive := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0777}},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "dir/file", Mode: 0777}, content: "content"},
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "dir/setuid", Mode: 04777}, content: "content"},
+		{header: &tar.Header{Typeflag: tar.TypeFifo, Name: "dir/fifo", Mode: 0777}},
+		{header: &tar.Header{Typeflag: tar.TypeChar, Name: "dir/null", Mode: 0777, Devmajor: 1, Devminor: 3}},
+		{header: &tar.Header{Typeflag: tar.TypeFifo, Name: "implicit/fifo", Mode: 0777}},
+	})
+
+	dest := t.TempDir()
+	if err := Extract(archive, dest, WithMaxPermissions(0755)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"dir", "dir/file", "dir/setuid", "dir/fifo", "dir/null", "implicit", "implicit/fifo"} {
+		fi, err := os.Stat(filepath.Join(dest, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode()&(os.ModePerm|os.ModeSetuid) != 0755 {
+			t.Fatalf("Expected %s to have mode 0755. Found %o instead.", name, fi.Mode()&(os.ModePerm|os.ModeSetuid))
+		}
+	}
+}
+
+func TestCalculateArchiveSHA256(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	expected, err := CreateSHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := CalculateArchiveSHA256(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected checksum %x. Received %x instead.", expected, checksum)
+	}
+
+	if _, err = exec.LookPath("sha256sum"); err != nil {
+		t.Skip("sha256sum not available.")
+	}
+	out, err := exec.Command("sha256sum", tarball).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum := strings.Fields(string(out))[0]; sum != hex.EncodeToString(checksum) {
+		t.Fatalf("Expected checksum %s as reported by sha256sum. Received %x instead.", sum, checksum)
+	}
+}
+
+func TestCreateFollowSymlinks(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "dir", "nested"), []byte("nested"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir", filepath.Join(src, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src, WithFollowSymlinks()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	types := make(map[string]byte)
+	r := tar.NewReader(f)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types[h.Name] = h.Typeflag
+		if h.Name == "link" {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "content" {
+				t.Fatalf("Expected content %q. Received %q instead.", "content", data)
+			}
+		}
+	}
+
+	expected := map[string]byte{
+		"dir/":           tar.TypeDir,
+		"dir/nested":     tar.TypeReg,
+		"file":           tar.TypeReg,
+		"link":           tar.TypeReg,
+		"linkdir/":       tar.TypeDir,
+		"linkdir/nested": tar.TypeReg,
+	}
+	if !reflect.DeepEqual(types, expected) {
+		t.Fatalf("Expected entries %v. Received %v instead.", expected, types)
+	}
+}
+
+func TestCreateFollowSymlinksLoop(t *testing.T) {
+	src := t.TempDir()
+	if err := os.Mkdir(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(src, "dir", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	err := Create(tarball, src, src, WithFollowSymlinks())
+	if !errors.Is(err, ErrSymlinkLoop) {
+		t.Fatalf("Expected ErrSymlinkLoop. Received %v instead.", err)
+	}
+}
+
+func TestCleanEntryName(t *testing.T) {
+	dir, err := os.Stat(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Stat(prefix + entries[6])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		f        os.FileInfo
+		path     string
+		prefix   string
+		expected string
+	}{
+		{file, "/a/b/file", "", "a/b/file"},
+		{file, "/a/b/file", "/", "a/b/file"},
+		{file, "/a/b/file", "/a", "b/file"},
+		{file, "/a/b/file", "/a/", "b/file"},
+		{file, "a/b/file", "a", "b/file"},
+		{file, "file", "", "file"},
+		{dir, "/a/b", "/a", "b/"},
+		{dir, "/a/b/", "/a", "b/"},
+		{dir, "a/b", "", "a/b/"},
+		{dir, "/a/b", "/a/b", ""},
+		{dir, "/a/b/", "/a/b", ""},
+		{dir, "/", "", ""},
+	}
+
+	for _, test := range tests {
+		if entry := CleanEntryName(test.f, test.path, test.prefix); entry != test.expected {
+			t.Fatalf("Expected entry %q for path %q and prefix %q. Received %q instead.", test.expected, test.path, test.prefix, entry)
+		}
+	}
+}
+
+func TestIsDataEmpty(t *testing.T) {
+	dirs := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "a/", Mode: 0755}},
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "a/b/", Mode: 0755}},
+	})
+	empty, err := IsDataEmpty(dirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !empty {
+		t.Fatal("Expected an archive containing only directories to be empty.")
+	}
+
+	mixed := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "a/", Mode: 0755}},
+		{header: &tar.Header{Typeflag: tar.TypeSymlink, Name: "a/link", Linkname: "target"}},
+	})
+	empty, err = IsDataEmpty(mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty {
+		t.Fatal("Expected an archive containing a symbolic link to not be empty.")
+	}
+}
+
+func TestCreateBlockingFactor(t *testing.T) {
+	for _, n := range []int{1, 3, 20} {
+		tarball := filepath.Join(t.TempDir(), archive)
+		if err := Create(tarball, prefix, prefix, WithBlockingFactor(n)); err != nil {
+			t.Fatal(err)
+		}
+
+		fi, err := os.Stat(tarball)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size()%int64(n*blockSize) != 0 {
+			t.Fatalf("Expected a size that is a multiple of %d. Received %d instead.", n*blockSize, fi.Size())
+		}
+
+		if err = Verify(tarball); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCreateRecordSize(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, prefix, prefix, WithRecordSize(1024)); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size()%1024 != 0 {
+		t.Fatalf("Expected a size that is a multiple of 1024. Received %d instead.", fi.Size())
+	}
+
+	if err = Verify(tarball); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Create(tarball, prefix, prefix, WithRecordSize(1000)); !errors.Is(err, ErrInvalidRecordSize) {
+		t.Fatalf("Expected ErrInvalidRecordSize. Received %v instead.", err)
+	}
+}
+
+func TestExtractCreateDestination(t *testing.T) {
+	tarball := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeReg, Name: "file", Mode: 0644}, content: "content"},
+	})
+
+	dest := filepath.Join(t.TempDir(), "missing", "dest")
+	if err := Extract(tarball, dest, WithCreateDestination(0700)); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() || fi.Mode().Perm() != 0700 {
+		t.Fatalf("Expected a directory with mode 0700. Found %s instead.", fi.Mode())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "content" {
+		t.Fatalf("Expected content %q. Received %q instead.", "content", data)
+	}
+}
+
+func TestExtractMaxPathDepth(t *testing.T) {
+	name := strings.Repeat("a/", 199) + "file"
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644}, "content"},
+	})
+
+	if err := Extract(tarball, t.TempDir()); !errors.Is(err, ErrPathTooDeep) {
+		t.Fatalf("Expected ErrPathTooDeep. Received %v instead.", err)
+	}
+
+	if err := Extract(tarball, t.TempDir(), WithMaxPathDepth(199)); !errors.Is(err, ErrPathTooDeep) {
+		t.Fatalf("Expected ErrPathTooDeep. Received %v instead.", err)
+	}
+
+	dest := t.TempDir()
+	if err := Extract(tarball, dest, WithMaxPathDepth(200)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, name)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Extract(tarball, t.TempDir(), WithMaxPathDepth(-1)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// statlessInfo hides the stat information of an os.FileInfo.
+type statlessInfo struct {
+	os.FileInfo
+}
+
+func (statlessInfo) Sys() interface{} {
+	return nil
+}
+
+func TestFileHeaderDevice(t *testing.T) {
+	dev := filepath.Join(t.TempDir(), "null")
+	if err := unix.Mknod(dev, unix.S_IFCHR|0666, int(unix.Mkdev(1, 3))); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []os.FileInfo{fi, statlessInfo{fi}} {
+		h, err := fileHeader(dev, "null", f, &Options{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Typeflag != tar.TypeChar || h.Devmajor != 1 || h.Devminor != 3 {
+			t.Fatalf("Expected character device 1:3. Received type %c %d:%d instead.", h.Typeflag, h.Devmajor, h.Devminor)
+		}
+	}
+
+	h := &tar.Header{Typeflag: tar.TypeBlock, Devmajor: -1, Devminor: 3}
+	if err = deviceNumbers(h, dev); !errors.Is(err, ErrInvalidDevice) {
+		t.Fatalf("Expected ErrInvalidDevice. Received %v instead.", err)
+	}
+}
+
+func TestCreateInProgress(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	block := WithXattrProgress(func(string, int) {
+		once.Do(func() {
+			close(started)
+			<-release
+		})
+	})
+
+	first := make(chan error)
+	go func() {
+		first <- Create(tarball, prefix, prefix, block)
+	}()
+	<-started
+
+	err := Create(filepath.Join(filepath.Dir(tarball), ".", archive), prefix, prefix)
+	close(release)
+	if !errors.Is(err, ErrArchiveInProgress) {
+		t.Fatalf("Expected ErrArchiveInProgress. Received %v instead.", err)
+	}
+	if err = <-first; err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Create(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreatePathTransform(t *testing.T) {
+	src := t.TempDir()
+	if err := os.Mkdir(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "dir/b"} {
+		if err := os.WriteFile(filepath.Join(src, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	err := Create(tarball, src, src, WithPathTransform(func(name string) (string, error) {
+		return "v1/" + strings.TrimSuffix(name, "/"), nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := readEntryNames(t, tarball)
+	sort.Strings(names)
+	if expected := []string{"v1/a", "v1/dir/", "v1/dir/b"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Received %v instead.", expected, names)
+	}
+
+	errRejected := errors.New("rejected")
+	reject := WithPathTransform(func(name string) (string, error) {
+		if name == "dir/b" {
+			return "", errRejected
+		}
+		return name, nil
+	})
+	if err = Create(tarball, src, src, reject); !errors.Is(err, errRejected) {
+		t.Fatalf("Expected the transform error. Received %v instead.", err)
+	}
+
+	if err = Create(tarball, src, src, reject, WithContinueOnError()); err != nil {
+		t.Fatal(err)
+	}
+	names = readEntryNames(t, tarball)
+	sort.Strings(names)
+	if expected := []string{"a", "dir/"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Received %v instead.", expected, names)
+	}
+}
+
+func TestExtractAbsoluteSymlinkPolicy(t *testing.T) {
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeDir, Name: "etc/", Mode: 0755}, ""},
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "etc/link", Linkname: "/etc/passwd", Mode: 0777}, ""},
+	})
+
+	dest := t.TempDir()
+	if err := Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "etc", "link")); err != nil || target != "passwd" {
+		t.Fatalf("Expected the link to be rewritten by default. Received %q, %v instead.", target, err)
+	}
+
+	dest = t.TempDir()
+	if err := Extract(tarball, dest, WithUnsafeSymlinkPolicy(UnsafeSymlinkAllow)); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "etc", "link")); err != nil || target != "/etc/passwd" {
+		t.Fatalf("Expected the link to be kept as is. Received %q, %v instead.", target, err)
+	}
+
+	err := Extract(tarball, t.TempDir(), WithAbsoluteSymlinkPolicy(AbsoluteSymlinkError))
+	if !errors.Is(err, ErrAbsoluteSymlink) {
+		t.Fatalf("Expected ErrAbsoluteSymlink. Received %v instead.", err)
+	}
+
+	dest = t.TempDir()
+	if err = Extract(tarball, dest, WithAbsoluteSymlinkPolicy(AbsoluteSymlinkSkip)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Lstat(filepath.Join(dest, "etc", "link")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the link to be skipped. Received %v instead.", err)
+	}
+
+	dest = t.TempDir()
+	if err = Extract(tarball, dest, WithAbsoluteSymlinkPolicy(AbsoluteSymlinkRelativize)); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "etc", "link")); err != nil || target != "passwd" {
+		t.Fatalf("Expected the link to point to passwd. Received %q, %v instead.", target, err)
+	}
+
+	tarball = writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "a/b/link", Linkname: "/../../etc/passwd", Mode: 0777}, ""},
+	})
+	dest = t.TempDir()
+	if err = Extract(tarball, dest, WithAbsoluteSymlinkPolicy(AbsoluteSymlinkRelativize)); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "a", "b", "link")); err != nil || target != "../../etc/passwd" {
+		t.Fatalf("Expected the link to stay below the root. Received %q, %v instead.", target, err)
+	}
+}
+
+func TestPAXDeviceRecords(t *testing.T) {
+	src := t.TempDir()
+	dev := filepath.Join(src, "dev")
+	if err := unix.Mknod(dev, unix.S_IFBLK|0600, int(unix.Mkdev(300, 70000))); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src, WithPAXDeviceRecords()); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := ExtractPAXRecords(tarball, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[paxDevMajor] != "300" || records[paxDevMinor] != "70000" {
+		t.Fatalf("Expected device records 300:70000. Received %v instead.", records)
+	}
+
+	dest := t.TempDir()
+	if err = Extract(tarball, dest, WithPAXDeviceRecords()); err != nil {
+		t.Fatal(err)
+	}
+	var st unix.Stat_t
+	if err = unix.Lstat(filepath.Join(dest, "dev"), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFBLK || unix.Major(st.Rdev) != 300 || unix.Minor(st.Rdev) != 70000 {
+		t.Fatalf("Expected block device 300:70000. Received mode %o %d:%d instead.", st.Mode, unix.Major(st.Rdev), unix.Minor(st.Rdev))
+	}
+
+	// The records take precedence over the header fields.
+	tarball = writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeChar, Name: "null", Mode: 0666, Devmajor: 1, Devminor: 3,
+			PAXRecords: map[string]string{paxDevMajor: "511", paxDevMinor: "256"}}, ""},
+	})
+	for _, tc := range []struct {
+		opts         []Option
+		major, minor uint32
+	}{
+		{nil, 1, 3},
+		{[]Option{WithPAXDeviceRecords()}, 511, 256},
+	} {
+		dest = t.TempDir()
+		if err = Extract(tarball, dest, tc.opts...); err != nil {
+			t.Fatal(err)
+		}
+		if err = unix.Lstat(filepath.Join(dest, "null"), &st); err != nil {
+			t.Fatal(err)
+		}
+		if unix.Major(st.Rdev) != tc.major || unix.Minor(st.Rdev) != tc.minor {
+			t.Fatalf("Expected device %d:%d. Received %d:%d instead.", tc.major, tc.minor, unix.Major(st.Rdev), unix.Minor(st.Rdev))
+		}
+	}
+}
+
+func TestCreateToWriterExtractFromReader(t *testing.T) {
+	var b bytes.Buffer
+	if err := CreateToWriter(&b, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	if err := ExtractFromReader(bytes.NewReader(b.Bytes()), dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, entries[1])); err != nil {
+		t.Fatal(err)
+	}
+
+	b.Reset()
+	created, err := CreateSHA256ToWriter(&b, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	expected, err := CreateSHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(created, expected) {
+		t.Fatalf("Expected checksum %x. Received %x instead.", expected, created)
+	}
+
+	extracted, err := ExtractSHA256FromReader(&b, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(extracted, expected) {
+		t.Fatalf("Expected checksum %x. Received %x instead.", expected, extracted)
+	}
+}
+
+func TestCreateHardLink(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	found := false
+	tr := tar.NewReader(f)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch h.Name {
+		case entries[2]:
+			if h.Typeflag != tar.TypeReg {
+				t.Fatalf("Expected %s to be a regular file. Received type %c instead.", h.Name, h.Typeflag)
+			}
+		case entries[3]:
+			if h.Typeflag != tar.TypeLink || h.Linkname != entries[2] {
+				t.Fatalf("Expected %s to be a hard link to %s. Received type %c and link %s instead.", h.Name, entries[2], h.Typeflag, h.Linkname)
+			}
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Expected %s to be archived.", entries[3])
+	}
+
+	dest := t.TempDir()
+	if err = Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := os.Stat(filepath.Join(dest, entries[2]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := os.Stat(filepath.Join(dest, entries[3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(target, link) {
+		t.Fatalf("Expected %s and %s to share an inode.", entries[2], entries[3])
+	}
+}
+
+func TestCreateExtractFIFO(t *testing.T) {
+	src := t.TempDir()
+	if err := unix.Mkfifo(filepath.Join(src, "fifo"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	h, err := tar.NewReader(f).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Typeflag != tar.TypeFifo || h.Size != 0 {
+		t.Fatalf("Expected empty FIFO entry. Received type %c with size %d instead.", h.Typeflag, h.Size)
+	}
+
+	dest := t.TempDir()
+	if err = Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(filepath.Join(dest, "fifo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeNamedPipe == 0 || fi.Mode().Perm() != 0640 {
+		t.Fatalf("Expected FIFO with mode 0640. Received %v instead.", fi.Mode())
+	}
+}
+
+func TestCreateExtractWithOptions(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{".hidden", "visible"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := CreateWithOptions(tarball, src, src, Options{ExcludeHidden: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"visible"}
+	names := readEntryNames(t, tarball)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Found %v instead.", expected, names)
+	}
+
+	dest := t.TempDir()
+	if err := ExtractWithOptions(tarball, dest, Options{Compression: CompressionGzip}); err == nil {
+		t.Fatalf("Expected extraction of an uncompressed archive as gzip to fail.")
+	}
+	if err := ExtractWithOptions(tarball, dest, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "visible")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateExtractWithOptionsFields(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "top", "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "top", "dir", "file"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(filepath.Join(src, "top", "dir", "file"), 1000, 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	created := sha256.New()
+	tarball := filepath.Join(t.TempDir(), archive)
+	err := CreateWithOptions(tarball, src, src, Options{
+		HashAlgorithm: created,
+		UIDMap:        func(id int) int { return id + 1 },
+		GIDMap:        func(id int) int { return id + 2 },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	extracted := sha256.New()
+	dest := t.TempDir()
+	err = ExtractWithOptions(tarball, dest, Options{
+		HashAlgorithm:   extracted,
+		GIDMap:          func(id int) int { return id - 2 },
+		StripComponents: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(created.Sum(nil), extracted.Sum(nil)) {
+		t.Fatalf("Expected the create and extract checksums to match. Received %x and %x instead.", created.Sum(nil), extracted.Sum(nil))
+	}
+
+	if _, err = os.Lstat(filepath.Join(dest, "top")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the leading component to be stripped. Received %v instead.", err)
+	}
+	fi, err := os.Lstat(filepath.Join(dest, "dir", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := fi.Sys().(*syscall.Stat_t)
+	if st.Uid != 1001 || st.Gid != 1000 {
+		t.Fatalf("Expected owner 1001:1000. Received %d:%d instead.", st.Uid, st.Gid)
+	}
+}
+
+func TestStripComponents(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		n        int
+		expected string
+		ok       bool
+	}{
+		{"a/b/c", 1, "b/c", true},
+		{"./a/b/", 1, "b/", true},
+		{"/a//b", 1, "b", true},
+		{"a/b", 2, "", false},
+		{"a", 1, "", false},
+	} {
+		stripped, ok := stripComponents(tc.name, tc.n)
+		if stripped != tc.expected || ok != tc.ok {
+			t.Fatalf("Expected %q stripped by %d to be %q, %v. Received %q, %v instead.", tc.name, tc.n, tc.expected, tc.ok, stripped, ok)
+		}
+	}
+}
+
+func TestExtractUnsafeSymlinkPolicy(t *testing.T) {
+	parent := t.TempDir()
+	outside := filepath.Join(parent, "outside")
+	if err := os.Mkdir(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(parent, "dest")
+
+	for _, link := range []string{outside, "../outside", "dir/../../outside"} {
+		tarball := writeTestArchive(t, []testEntry{
+			{&tar.Header{Typeflag: tar.TypeSymlink, Name: "evil", Linkname: link, Mode: 0777}, ""},
+			{&tar.Header{Typeflag: tar.TypeReg, Name: "evil/file", Mode: 0644}, "pwned"},
+		})
+
+		err := Extract(tarball, dest, WithUnsafeSymlinkPolicy(UnsafeSymlinkError), WithOverwrite(OverwriteReplace))
+		if !errors.Is(err, ErrUnsafeSymlink) {
+			t.Fatalf("Expected ErrUnsafeSymlink for %s. Received %v instead.", link, err)
+		}
+		if _, err = os.Lstat(filepath.Join(outside, "file")); !os.IsNotExist(err) {
+			t.Fatalf("Expected nothing to be written through %s.", link)
+		}
+	}
+
+	for _, tc := range []struct {
+		name, link, expected string
+	}{
+		{"etc/link", "/etc/", "."},
+		{"etc/link", "/etc/passwd", "passwd"},
+		{"a/link", "../../../etc/passwd", "../etc/passwd"},
+		{"a/b/link", "../sibling", "../sibling"},
+	} {
+		tarball := writeTestArchive(t, []testEntry{
+			{&tar.Header{Typeflag: tar.TypeSymlink, Name: tc.name, Linkname: tc.link, Mode: 0777}, ""},
+		})
+
+		dest := t.TempDir()
+		if err := Extract(tarball, dest, WithUnsafeSymlinkPolicy(UnsafeSymlinkRewrite)); err != nil {
+			t.Fatal(err)
+		}
+		if target, err := os.Readlink(filepath.Join(dest, tc.name)); err != nil || target != tc.expected {
+			t.Fatalf("Expected %s to point to %s. Received %q, %v instead.", tc.name, tc.expected, target, err)
+		}
+	}
+}
+
+func TestCreateExcludeGlob(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{".git/config", "node_modules/pkg/index.js", "dir/.git/HEAD", "dir/file", "dir/debug.log", "file"} {
+		p := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src, WithExcludeGlob(".git", "node_modules", "*.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"dir/", "dir/file", "file"}
+	names := readEntryNames(t, tarball)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Found %v instead.", expected, names)
+	}
+
+	if err := Create(tarball, src, src, WithExcludeGlob("dir/*")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []string{".git/", ".git/config", "dir/", "file", "node_modules/", "node_modules/pkg/", "node_modules/pkg/index.js"}
+	names = readEntryNames(t, tarball)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected entries %v. Found %v instead.", expected, names)
+	}
+
+	if err := Create(tarball, src, src, WithExcludeGlob("[")); !errors.Is(err, filepath.ErrBadPattern) {
+		t.Fatalf("Expected filepath.ErrBadPattern. Received %v instead.", err)
+	}
+}
+
+func TestExtractChainedSymlinks(t *testing.T) {
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "a", Linkname: "."}, ""},
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "a/b", Linkname: ".."}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "b/pwned", Mode: 0644}, "pwned"},
+	})
+
+	for _, tc := range []struct {
+		policy   UnsafeSymlinkPolicy
+		expected error
+	}{
+		{UnsafeSymlinkError, ErrUnsafeSymlink},
+		{UnsafeSymlinkAllow, ErrUnsafePath},
+		{UnsafeSymlinkRewrite, nil},
+	} {
+		parent := t.TempDir()
+		dest := filepath.Join(parent, "dest")
+
+		err := Extract(tarball, dest, WithUnsafeSymlinkPolicy(tc.policy), WithAbsoluteSymlinkPolicy(AbsoluteSymlinkError))
+		if !errors.Is(err, tc.expected) {
+			t.Fatalf("Expected %v. Received %v instead.", tc.expected, err)
+		}
+		if _, err = os.Lstat(filepath.Join(parent, "pwned")); !os.IsNotExist(err) {
+			t.Fatalf("Expected pwned to not be written outside of the destination.")
+		}
+	}
+
+	dest := t.TempDir()
+	if err := Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(dest, "b")); err != nil || target != "." {
+		t.Fatalf("Expected b to be rewritten to point to the destination. Received %q, %v instead.", target, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dest, "pwned")); err != nil {
+		t.Fatal(err)
+	}
+}
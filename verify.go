@@ -0,0 +1,280 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+)
+
+// knownTypes are the entry types Verify accepts.
+var knownTypes = map[byte]bool{
+	tar.TypeReg:           true,
+	tar.TypeRegA:          true,
+	tar.TypeLink:          true,
+	tar.TypeSymlink:       true,
+	tar.TypeChar:          true,
+	tar.TypeBlock:         true,
+	tar.TypeDir:           true,
+	tar.TypeFifo:          true,
+	tar.TypeCont:          true,
+	tar.TypeXGlobalHeader: true,
+	tar.TypeGNUSparse:     true,
+	typeGNUVolHeader:      true,
+	typeGNUDumpDir:        true,
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// verifyWritten reads back the n bytes of entry written to rws at start and
+// compares their SHA256 hash to expected. The offset of rws is restored
+// afterwards.
+func verifyWritten(rws io.ReadWriteSeeker, entry string, start int64, n int64, expected []byte) error {
+	end, err := rws.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	if _, err = rws.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	s := sha256.New()
+	if _, err = io.CopyN(s, rws, n); err != nil {
+		return err
+	}
+
+	if _, err = rws.Seek(end, io.SeekStart); err != nil {
+		return err
+	}
+
+	if actual := s.Sum(nil); !bytes.Equal(actual, expected) {
+		return &WriteVerificationError{Entry: entry, Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
+
+// Verify checks the headers of archive for consistency without extracting it.
+// Entries need to have a known type and valid UTF-8 names and PAX records.
+// Symbolic links need a target. The archive has to end with an end-of-archive
+// marker of two zero blocks which may only be followed by zero padding. All
+// violations found are returned as a MultiError. A header that cannot be
+// parsed at all ends verification.
+func Verify(archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var errs MultiError
+	c := &countingReader{r: f}
+	r := tar.NewReader(c)
+
+	var end int64
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, err)
+			return errs.err()
+		}
+
+		errs = append(errs, verifyHeader(h)...)
+
+		if _, err = io.Copy(io.Discard, r); err != nil {
+			errs = append(errs, fmt.Errorf("entry %q: %w", h.Name, err))
+			return errs.err()
+		}
+		end = (c.n + blockSize - 1) / blockSize * blockSize
+	}
+
+	if _, err = f.Seek(end, io.SeekStart); err != nil {
+		return err
+	}
+	trailer, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if len(trailer) < 2*blockSize || !bytes.Equal(trailer, make([]byte, len(trailer))) {
+		errs = append(errs, errors.New("Archive does not end with an end-of-archive marker."))
+	}
+
+	return errs.err()
+}
+
+// VerifySHA256 reports whether the SHA256-hash checksum of archive matches
+// checksum. Like ExtractSHA256 the checksum is computed over the uncompressed
+// tar stream so it can be compared to the checksums returned by CreateSHA256
+// and ExtractSHA256. Nothing is extracted.
+func VerifySHA256(archive string, checksum []byte) (bool, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return VerifySHA256Reader(f, checksum)
+}
+
+// VerifySHA256Reader reports whether the SHA256-hash checksum of the tar stream
+// read from r matches checksum. See VerifySHA256.
+func VerifySHA256Reader(r io.Reader, checksum []byte) (bool, error) {
+	c, _, err := decompressReader(r, &Options{})
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+
+	// Skipping entry content reads it through the hash just like extraction
+	// does as the tee is not seekable.
+	h := sha256.New()
+	tr := tar.NewReader(io.TeeReader(c, h))
+	for {
+		_, err = tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return bytes.Equal(h.Sum(nil), checksum), nil
+}
+
+// verifyHeader returns all consistency violations found in h.
+func verifyHeader(h *tar.Header) (errs []error) {
+	if h.Size < 0 {
+		errs = append(errs, fmt.Errorf("Entry %q has negative size %d.", h.Name, h.Size))
+	}
+	if !knownTypes[h.Typeflag] {
+		errs = append(errs, fmt.Errorf("Entry %q has unknown type %q.", h.Name, h.Typeflag))
+	}
+	if h.Typeflag == tar.TypeSymlink && h.Linkname == "" {
+		errs = append(errs, fmt.Errorf("Symbolic link %q has no target.", h.Name))
+	}
+	if !utf8.ValidString(h.Name) {
+		errs = append(errs, fmt.Errorf("Entry %q has a name that is not valid UTF-8.", h.Name))
+	}
+	keys := make([]string, 0, len(h.PAXRecords))
+	for k := range h.PAXRecords {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !utf8.ValidString(k) || !utf8.ValidString(h.PAXRecords[k]) {
+			errs = append(errs, fmt.Errorf("Entry %q has PAX record %q that is not valid UTF-8.", h.Name, k))
+		}
+	}
+
+	return
+}
+
+// VerifyExtract compares the directory extractedPath with the archive it was
+// extracted from. The content of regular files is compared by their SHA256
+// hash. Directories are compared by existence and mode, symbolic links by
+// existence only. The names of all entries that do not match are returned.
+// Files found in extractedPath without a corresponding entry are ignored.
+func VerifyExtract(archive string, extractedPath string) ([]string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mismatches []string
+	r := tar.NewReader(f)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Like extraction, skip headers that describe no file.
+		switch h.Typeflag {
+		case tar.TypeXGlobalHeader, typeGNUVolHeader:
+			continue
+		}
+
+		ok, err := verifyEntry(filepath.Join(extractedPath, h.Name), h, r)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			mismatches = append(mismatches, h.Name)
+		}
+	}
+
+	return mismatches, nil
+}
+
+// verifyEntry reports whether the file at path matches the archive entry h.
+func verifyEntry(path string, h *tar.Header, r io.Reader) (bool, error) {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch h.Typeflag {
+	case tar.TypeDir:
+		return fi.IsDir() && fi.Mode().Perm() == h.FileInfo().Mode().Perm(), nil
+	case tar.TypeSymlink:
+		return fi.Mode()&os.ModeSymlink == os.ModeSymlink, nil
+	case tar.TypeReg, tar.TypeGNUSparse:
+		if !fi.Mode().IsRegular() {
+			return false, nil
+		}
+
+		s := sha256.New()
+		if _, err = io.Copy(s, r); err != nil {
+			return false, err
+		}
+
+		sum, err := hashFile(path)
+		if err != nil {
+			return false, err
+		}
+
+		return bytes.Equal(sum, s.Sum(nil)), nil
+	}
+
+	return true, nil
+}
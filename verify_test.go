@@ -0,0 +1,274 @@
+package tarski
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVerifyExtract(t *testing.T) {
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755}, ""},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/same", Mode: 0644}, "same"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/modified", Mode: 0644}, "original"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "dir/removed", Mode: 0644}, "removed"},
+		{&tar.Header{Typeflag: tar.TypeSymlink, Name: "dir/link", Linkname: "same"}, ""},
+	})
+
+	dest := t.TempDir()
+	if err := Extract(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err := VerifyExtract(tarball, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Fatalf("Expected no mismatches. Received %v instead.", mismatches)
+	}
+
+	if err = os.WriteFile(filepath.Join(dest, "dir", "modified"), []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(filepath.Join(dest, "dir", "removed")); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err = VerifyExtract(tarball, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"dir/modified", "dir/removed"}
+	if !reflect.DeepEqual(mismatches, expected) {
+		t.Fatalf("Expected mismatches %v. Received %v instead.", expected, mismatches)
+	}
+}
+
+func TestVerifyExtractSkipsMetadataHeaders(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	err := CreateWithComment(tarball, prefix, prefix, "volume label", WithGlobalPAXRecord("tarski.host", "example"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	if err = Extract(tarball, dest, WithSkipVolumeHeaders()); err != nil {
+		t.Fatal(err)
+	}
+
+	mismatches, err := VerifyExtract(tarball, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Fatalf("Expected no mismatches. Received %v instead.", mismatches)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(tarball); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := tar.NewWriter(f)
+	headers := []*tar.Header{
+		{Typeflag: tar.TypeReg, Name: "valid", Mode: 0644},
+		{Typeflag: 'Z', Name: "unknown", Mode: 0644},
+		{Typeflag: tar.TypeSymlink, Name: "dangling", Mode: 0777},
+		{Typeflag: tar.TypeReg, Name: "invalid\xff", Mode: 0644, Format: tar.FormatPAX},
+		{Typeflag: tar.TypeReg, Name: "record", Mode: 0644, PAXRecords: map[string]string{"tarski.test": "\xff"}},
+	}
+	for _, h := range headers {
+		if err = w.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Flush instead of Close so the end-of-archive marker is missing.
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = Verify(tarball)
+	var errs MultiError
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected a MultiError. Received %v instead.", err)
+	}
+	// The invalid name is reported for the header and its PAX path record.
+	if len(errs) != 6 {
+		t.Fatalf("Expected 6 violations. Received %d instead: %v", len(errs), errs)
+	}
+}
+
+// flippingFile is an in-memory io.ReadWriteSeeker that flips the lowest bit of
+// the byte written at offset flip.
+type flippingFile struct {
+	data []byte
+	off  int64
+	flip int64
+}
+
+func (f *flippingFile) Write(p []byte) (int, error) {
+	for i, c := range p {
+		pos := f.off + int64(i)
+		if pos == f.flip {
+			c ^= 1
+		}
+		if pos < int64(len(f.data)) {
+			f.data[pos] = c
+		} else {
+			f.data = append(f.data, c)
+		}
+	}
+	f.off += int64(len(p))
+
+	return len(p), nil
+}
+
+func (f *flippingFile) Read(p []byte) (int, error) {
+	if f.off >= int64(len(f.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data[f.off:])
+	f.off += int64(n)
+
+	return n, nil
+}
+
+func (f *flippingFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += int64(len(f.data))
+	}
+	f.off = offset
+
+	return offset, nil
+}
+
+func TestCreateVerifyAfterWrite(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "file"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src, WithVerifyAfterWrite()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The content of the only entry starts after its header block.
+	f := &flippingFile{flip: blockSize + 3}
+	err := createStream(f, src, src, nil, newOptions([]Option{WithVerifyAfterWrite()}))
+	var verr *WriteVerificationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a WriteVerificationError. Received %v instead.", err)
+	}
+	if verr.Entry != "file" {
+		t.Fatalf("Expected the mismatch to be reported for file. Received %s instead.", verr.Entry)
+	}
+
+	if err = Create(tarball, src, src, WithVerifyAfterWrite(), WithBzip2()); err == nil {
+		t.Fatal("Expected verifying a compressed archive to fail.")
+	}
+}
+
+func TestExtractVerifyEntryHash(t *testing.T) {
+	sum := sha256.Sum256([]byte("content"))
+	good := hex.EncodeToString(sum[:])
+	bad := strings.Repeat("0", len(good))
+
+	tarball := writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "good", Mode: 0644, PAXRecords: map[string]string{paxEntrySHA256: good}}, "content"},
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "unhashed", Mode: 0644}, "content"},
+	})
+	if err := Extract(tarball, t.TempDir(), WithVerifyEntryHash()); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball = writeTestArchive(t, []testEntry{
+		{&tar.Header{Typeflag: tar.TypeReg, Name: "bad", Mode: 0644, PAXRecords: map[string]string{paxEntrySHA256: bad}}, "content"},
+	})
+	err := Extract(tarball, t.TempDir(), WithVerifyEntryHash())
+	var merr *EntryHashMismatchError
+	if !errors.As(err, &merr) {
+		t.Fatalf("Expected an EntryHashMismatchError. Received %v instead.", err)
+	}
+	if merr.Name != "bad" || merr.Expected != bad || merr.Actual != good {
+		t.Fatalf("Expected a mismatch of %s for bad. Received %+v instead.", good, merr)
+	}
+
+	if err = Extract(tarball, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifySHA256(t *testing.T) {
+	dir := t.TempDir()
+	tarball := filepath.Join(dir, archive)
+	checksum, err := CreateSHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := filepath.Join(dir, "test.tar.gz")
+	gzipped, err := CreateGzipSHA256(compressed, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extracted, err := ExtractGzipSHA256(compressed, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		archive  string
+		checksum []byte
+	}{{tarball, checksum}, {compressed, gzipped}, {compressed, extracted}} {
+		ok, err := VerifySHA256(tc.archive, tc.checksum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatalf("Expected %s to match checksum %x.", tc.archive, tc.checksum)
+		}
+	}
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	mismatch := append([]byte{}, checksum...)
+	mismatch[0] ^= 0xff
+	ok, err := VerifySHA256Reader(f, mismatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatalf("Expected checksum %x not to match.", mismatch)
+	}
+
+	if _, err = VerifySHA256(filepath.Join(dir, "missing"), checksum); !os.IsNotExist(err) {
+		t.Fatalf("Expected a not exist error. Received %v instead.", err)
+	}
+}
@@ -0,0 +1,65 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bufio"
+	"io"
+)
+
+// Writer writes a tar archive entry by entry. Writes to the underlying writer
+// are buffered until Flush or Close is called.
+type Writer struct {
+	tw *tar.Writer
+	bw *bufio.Writer
+	w  io.Writer
+}
+
+// NewWriter creates a new Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	bw := bufio.NewWriter(w)
+
+	return &Writer{tw: tar.NewWriter(bw), bw: bw, w: w}
+}
+
+// WriteHeader writes h and prepares to accept the content of the entry.
+func (w *Writer) WriteHeader(h *tar.Header) error {
+	return w.tw.WriteHeader(h)
+}
+
+// Write writes to the content of the current entry.
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.tw.Write(p)
+}
+
+// Flush writes the padding of the current entry and all buffered data to the
+// underlying writer. If the underlying writer can be flushed itself, e.g. a
+// http.ResponseWriter, it is flushed as well. The content of the current entry
+// must have been written completely.
+func (w *Writer) Flush() error {
+	if err := w.tw.Flush(); err != nil {
+		return err
+	}
+
+	if err := w.bw.Flush(); err != nil {
+		return err
+	}
+
+	switch f := w.w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+
+	return nil
+}
+
+// Close writes the end-of-archive marker and flushes all buffered data to the
+// underlying writer. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+
+	return w.bw.Flush()
+}
@@ -0,0 +1,64 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+)
+
+type flushRecorder struct {
+	bytes.Buffer
+	flushed int
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushed++
+}
+
+func TestWriterFlush(t *testing.T) {
+	var b flushRecorder
+	w := NewWriter(&b)
+
+	content := "streamed before the archive is closed"
+	if err := w.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "first", Mode: 0644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.Len() != 0 {
+		t.Fatalf("Expected writes to be buffered before Flush.")
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if b.flushed != 1 {
+		t.Fatalf("Expected the underlying writer to be flushed.")
+	}
+
+	r := tar.NewReader(bytes.NewReader(b.Bytes()))
+	h, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Name != "first" {
+		t.Fatalf("Expected entry first. Received %s instead.", h.Name)
+	}
+	found, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(found) != content {
+		t.Fatalf("Expected content %q. Received %q instead.", content, string(found))
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len()%blockSize != 0 || b.Len() < 4*blockSize {
+		t.Fatalf("Expected a complete archive after Close. Received %d bytes.", b.Len())
+	}
+}
@@ -0,0 +1,532 @@
+package tarski
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// This uses ssize_t llistxattr(const char *path, char *list, size_t size); to
+// handle symbolic links (should it in the future be possible to set extended
+// attributed on symlinks): If path is a symbolic link the extended attributes
+// associated with the link itself are retrieved.
+func llistxattr(path string, list []byte) (sz int, err error) {
+	var _p0 *byte
+	_p0, err = unix.BytePtrFromString(path)
+	if err != nil {
+		return
+	}
+	var _p1 unsafe.Pointer
+	if len(list) > 0 {
+		_p1 = unsafe.Pointer(&list[0])
+	} else {
+		_p1 = unsafe.Pointer(nil)
+	}
+	r0, _, e1 := unix.Syscall(unix.SYS_LLISTXATTR, uintptr(unsafe.Pointer(_p0)), uintptr(_p1), uintptr(len(list)))
+	sz = int(r0)
+	if e1 != 0 {
+		err = e1
+	}
+	return
+}
+
+// GetAllXattr retrieves all extended attributes associated with a file,
+// directory or symbolic link.
+func GetAllXattr(path string) (xattrs map[string]string, err error) {
+	raw, err := getAllXattr(path)
+	if err != nil {
+		return nil, unsupportedXattr(path, err)
+	}
+
+	return xattrStrings(raw), nil
+}
+
+// xattrStrings converts extended attribute values to strings as stored in
+// tar.Header.Xattrs. It returns nil if there are no attributes.
+func xattrStrings(raw map[string][]byte) map[string]string {
+	if raw == nil {
+		return nil
+	}
+
+	xattrs := make(map[string]string, len(raw))
+	for k, v := range raw {
+		xattrs[k] = string(v)
+	}
+
+	return xattrs
+}
+
+// GetAllXattrContext retrieves all extended attributes associated with a file,
+// directory or symbolic link like GetAllXattr but returns ctx.Err() if ctx is
+// done before retrieval has finished. A blocking system call cannot be
+// interrupted so retrieval carries on in the background and its result is
+// discarded.
+func GetAllXattrContext(ctx context.Context, path string) (map[string][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		xattrs map[string][]byte
+		err    error
+	}
+
+	c := make(chan result, 1)
+	go func() {
+		xattrs, err := getAllXattr(path)
+		c <- result{xattrs, unsupportedXattr(path, err)}
+	}()
+
+	select {
+	case res := <-c:
+		return res.xattrs, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// xattrRetries is the number of times GetXattr retries retrieving a value that
+// changed size in between determining its size and reading it.
+const xattrRetries = 3
+
+// GetXattr retrieves the extended attribute name of path. It is the preferred
+// interface for single attributes whereas GetAllXattr should be used to
+// retrieve all attributes of a file. Symbolic links are followed.
+func GetXattr(path string, name string) ([]byte, error) {
+	return getXattr(path, name, unix.Getxattr)
+}
+
+// getXattr retrieves the extended attribute name of path with get, either
+// unix.Getxattr or unix.Lgetxattr.
+func getXattr(path string, name string, get func(path string, attr string, dest []byte) (int, error)) ([]byte, error) {
+	for i := 0; i <= xattrRetries; i++ {
+		pre, err := get(path, name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		dest := make([]byte, pre)
+		post, err := get(path, name, dest)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if post != pre {
+			continue
+		}
+
+		return dest, nil
+	}
+
+	return nil, fmt.Errorf("%s: %w", name, errXattrChanged)
+}
+
+// SetXattr sets the extended attribute name of path to value. It is the
+// preferred interface for single attributes whereas SetAllXattr should be used
+// to set multiple attributes. Symbolic links are followed.
+func SetXattr(path string, name string, value []byte) error {
+	if err := unix.Setxattr(path, name, value, 0); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+// unsupportedXattr turns ENOTSUP errors for path into an
+// *XattrUnsupportedError.
+func unsupportedXattr(path string, err error) error {
+	if errors.Is(err, unix.ENOTSUP) {
+		return &XattrUnsupportedError{Path: path, Err: err}
+	}
+
+	return err
+}
+
+// GetXattrsByNamespace retrieves all extended attributes associated with a
+// file, directory or symbolic link grouped by their namespace, e.g. "user"
+// or "security". The names in each group lack the namespace prefix.
+func GetXattrsByNamespace(path string) (map[string]map[string][]byte, error) {
+	xattrs, err := getAllXattr(path)
+	if err != nil {
+		return nil, unsupportedXattr(path, err)
+	}
+
+	groups := make(map[string]map[string][]byte)
+	for name, value := range xattrs {
+		ns, attr, _ := strings.Cut(name, ".")
+		if groups[ns] == nil {
+			groups[ns] = make(map[string][]byte)
+		}
+		groups[ns][attr] = value
+	}
+
+	return groups, nil
+}
+
+// GetXattrNamespace retrieves the extended attributes in namespace associated
+// with a file, directory or symbolic link. The names lack the namespace
+// prefix. Only the values of matching attributes are read.
+func GetXattrNamespace(path string, namespace string) (map[string][]byte, error) {
+	names, err := ListXattrs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	xattrs := make(map[string][]byte)
+	for _, name := range names {
+		attr, ok := strings.CutPrefix(name, namespace+".")
+		if !ok {
+			continue
+		}
+
+		value, err := getXattr(path, name, unix.Lgetxattr)
+		if err != nil {
+			return nil, err
+		}
+		xattrs[attr] = value
+	}
+
+	return xattrs, nil
+}
+
+// errXattrChanged is returned when the extended attributes of a file change
+// while they are being retrieved.
+var errXattrChanged = errors.New("Extended attributes changed during retrieval.")
+
+// ListXattrs retrieves the names of all extended attributes associated with a
+// file, directory or symbolic link without their values. Symbolic links are
+// not followed.
+func ListXattrs(path string) ([]string, error) {
+	names, err := listXattr(path)
+	return names, unsupportedXattr(path, err)
+}
+
+// listXattr retrieves the names of all extended attributes associated with a
+// file, directory or symbolic link.
+func listXattr(path string) ([]string, error) {
+	pre, err := llistxattr(path, nil)
+	if err != nil || pre < 0 {
+		return nil, err
+	}
+	if pre == 0 {
+		return nil, nil
+	}
+
+	dest := make([]byte, pre)
+
+	post, err := llistxattr(path, dest)
+	if err != nil || post < 0 {
+		return nil, err
+	}
+	if post != pre {
+		return nil, errXattrChanged
+	}
+
+	return parseXattrList(dest), nil
+}
+
+// parseXattrList splits the list of names returned by *listxattr.
+func parseXattrList(list []byte) []string {
+	if len(list) == 0 {
+		return nil
+	}
+
+	// *listxattr functions return a list of  names  as  an unordered array
+	// of null-terminated character strings (attribute names are separated
+	// by null bytes ('\0')), like this: user.name1\0system.name1\0user.name2\0
+	// Since we split at the '\0'-byte the last element of the slice will be
+	// the empty string. We remove it. A list lacking the final null byte
+	// keeps its last name.
+	split := strings.Split(string(list), "\x00")
+	if split[len(split)-1] == "" {
+		split = split[:len(split)-1]
+	}
+
+	return split
+}
+
+// GetAllXattrWithProgress retrieves all extended attributes associated with a
+// file, directory or symbolic link like GetAllXattr. fn is called after each
+// attribute has been read with its name and the size of its value.
+func GetAllXattrWithProgress(path string, fn func(attrName string, bytesRead int)) (map[string][]byte, error) {
+	xattrs, err := getAllXattrProgress(path, fn)
+	if err != nil {
+		return nil, unsupportedXattr(path, err)
+	}
+
+	return xattrs, nil
+}
+
+func getAllXattr(path string) (xattrs map[string][]byte, err error) {
+	return getAllXattrProgress(path, nil)
+}
+
+func getAllXattrProgress(path string, fn func(attrName string, bytesRead int)) (xattrs map[string][]byte, err error) {
+	split, err := listXattr(path)
+	if err != nil || split == nil {
+		return nil, err
+	}
+
+	xattrs = make(map[string][]byte, len(split))
+
+	for _, x := range split {
+		xattr := string(x)
+		pre, err := unix.Lgetxattr(path, xattr, nil)
+		if err != nil || pre < 0 {
+			return nil, err
+		}
+		if pre == 0 {
+			return nil, errors.New("No valid extended attribute value found.")
+		}
+
+		dest := make([]byte, pre)
+		post, err := unix.Lgetxattr(path, xattr, dest)
+		if err != nil || post < 0 {
+			return nil, err
+		}
+		if post != pre {
+			return nil, errXattrChanged
+		}
+
+		xattrs[xattr] = dest
+		if fn != nil {
+			fn(xattr, post)
+		}
+	}
+
+	return xattrs, nil
+}
+
+// GetAllXattrFromFd retrieves all extended attributes of the file referred to
+// by fd. Unlike GetAllXattr the file is not looked up by path for every system
+// call so all attributes are guaranteed to belong to the same file. Retrieval
+// is retried if the attributes change in between.
+func GetAllXattrFromFd(fd uintptr) (xattrs map[string][]byte, err error) {
+	for i := 0; i <= xattrRetries; i++ {
+		xattrs, err = getAllXattrFd(int(fd))
+		if !errors.Is(err, errXattrChanged) && !errors.Is(err, unix.ERANGE) {
+			return
+		}
+	}
+
+	return
+}
+
+// getAllXattrFd retrieves all extended attributes of the file referred to by
+// fd.
+func getAllXattrFd(fd int) (map[string][]byte, error) {
+	pre, err := unix.Flistxattr(fd, nil)
+	if err != nil || pre <= 0 {
+		return nil, err
+	}
+
+	list := make([]byte, pre)
+	post, err := unix.Flistxattr(fd, list)
+	if err != nil {
+		return nil, err
+	}
+	if post != pre {
+		return nil, errXattrChanged
+	}
+
+	xattrs := make(map[string][]byte)
+	for _, name := range parseXattrList(list) {
+		pre, err := unix.Fgetxattr(fd, name, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		dest := make([]byte, pre)
+		post, err := unix.Fgetxattr(fd, name, dest)
+		if err != nil {
+			return nil, err
+		}
+		if post != pre {
+			return nil, errXattrChanged
+		}
+
+		xattrs[name] = dest
+	}
+
+	return xattrs, nil
+}
+
+// RemoveAllXattr removes all extended attributes associated with a file,
+// directory or symbolic link. Symbolic links are not followed.
+func RemoveAllXattr(path string) error {
+	names, err := listXattr(path)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err = unix.Lremovexattr(path, name); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// MergeXattr returns a new map holding all extended attributes of base with
+// those of override taking precedence. Attributes set to nil in override are
+// removed from the result while empty non-nil values are kept. Neither base
+// nor override are modified but the values are not copied.
+func MergeXattr(base, override map[string][]byte) map[string][]byte {
+	merged := make(map[string][]byte, len(base)+len(override))
+	for name, value := range base {
+		merged[name] = value
+	}
+
+	for name, value := range override {
+		if value == nil {
+			delete(merged, name)
+			continue
+		}
+		merged[name] = value
+	}
+
+	return merged
+}
+
+// SetAllXattr sets all extended attributes in xattrs on a file, directory or
+// symbolic link. It is the inverse of GetAllXattr and GetAllXattrContext.
+// Symbolic links are not followed. With WithContinueOnXattrErrors() all
+// attributes are attempted and the failures are returned as a MultiError.
+func SetAllXattr(path string, xattrs map[string][]byte, opts ...Option) error {
+	return setAllXattr(path, xattrs, newOptions(opts))
+}
+
+// SetAllXattrFd sets all extended attributes in xattrs on the file referred to
+// by fd. With WithContinueOnXattrErrors() all attributes are attempted and the
+// failures are returned as a MultiError.
+func SetAllXattrFd(fd int, xattrs map[string][]byte, opts ...Option) error {
+	return applyXattrs(xattrs, newOptions(opts), func(attr string, data []byte) error {
+		return unix.Fsetxattr(fd, attr, data, 0)
+	})
+}
+
+// BatchSetXattrFd sets all extended attributes in xattrs on the file referred
+// to by fd. It is SetAllXattrFd without options.
+func BatchSetXattrFd(fd int, xattrs map[string][]byte) error {
+	return SetAllXattrFd(fd, xattrs)
+}
+
+// BatchSetXattr sets all extended attributes in xattrs on path like
+// SetAllXattr but resolves path only once. The file is opened read-only
+// since fsetxattr does not accept O_PATH file descriptors. Only regular files
+// and directories are opened, so FIFOs and devices are never opened as a side
+// effect. Everything else, including symbolic links and files the caller
+// cannot read, is left to SetAllXattr which resolves path for every
+// attribute.
+func BatchSetXattr(path string, xattrs map[string][]byte, opts ...Option) error {
+	if len(xattrs) == 0 {
+		return nil
+	}
+
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() && !fi.IsDir() {
+		return SetAllXattr(path, xattrs, opts...)
+	}
+
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_CLOEXEC|unix.O_NONBLOCK, 0)
+	if errors.Is(err, unix.ELOOP) || errors.Is(err, unix.EACCES) {
+		return SetAllXattr(path, xattrs, opts...)
+	}
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	return applyXattrs(xattrs, newOptions(opts), func(attr string, data []byte) error {
+		return unsupportedXattr(path, unix.Fsetxattr(fd, attr, data, 0))
+	})
+}
+
+func setAllXattr(path string, xattrs map[string][]byte, o *Options) error {
+	if len(xattrs) == 0 {
+		return nil
+	}
+
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	set := unix.Setxattr
+	if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
+		set = unix.Lsetxattr
+	}
+
+	return applyXattrs(xattrs, o, func(attr string, data []byte) error {
+		return unsupportedXattr(path, set(path, attr, data, 0))
+	})
+}
+
+// applyXattrs calls set for each extended attribute in xattrs in a
+// deterministic order. During layer extraction attributes that cannot be set
+// due to missing privileges or missing filesystem support are skipped.
+func applyXattrs(xattrs map[string][]byte, o *Options, set func(attr string, data []byte) error) error {
+	attrs := make([]string, 0, len(xattrs))
+	for attr := range xattrs {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	var errs MultiError
+	for _, attr := range attrs {
+		data := xattrs[attr]
+
+		var err error
+		if o.XattrSizeLimit > 0 && len(data) > o.XattrSizeLimit {
+			switch o.XattrOversize {
+			case OversizeSkip:
+				continue
+			case OversizeTruncate:
+				data = data[:o.XattrSizeLimit]
+			default:
+				err = ErrXattrTooLarge
+			}
+		}
+
+		if err == nil {
+			err = set(attr, data)
+		}
+		if err != nil && o.layer && (errors.Is(err, unix.EPERM) || errors.Is(err, unix.ENOTSUP)) {
+			continue
+		}
+		if err != nil && o.SkipUnsupportedXattr && errors.Is(err, unix.ENOTSUP) {
+			log.Printf("Warning: not setting extended attribute %s: %v", attr, err)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", attr, err))
+			if !o.ContinueOnXattrErrors {
+				break
+			}
+		}
+	}
+
+	return errs.err()
+}
+
+// setXattrs sets the extended attributes found in a tar header on path.
+func setXattrs(path string, xattrs map[string]string, o *Options) error {
+	raw := make(map[string][]byte, len(xattrs))
+	for attr, data := range xattrs {
+		raw[attr] = []byte(data)
+	}
+
+	return setAllXattr(path, raw, o)
+}
@@ -0,0 +1,768 @@
+package tarski
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetAllXattrContext(t *testing.T) {
+	xattrs, err := GetAllXattrContext(context.Background(), prefix+entries[6])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range testxattr {
+		if string(xattrs[k]) != v {
+			t.Fatalf("Expected to find extended attribute %s with a value of %s but did not find it.", k, v)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	if _, err = GetAllXattrContext(ctx, prefix+entries[6]); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded. Received %v instead.", err)
+	}
+}
+
+func TestSetAllXattr(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	xattrs := map[string][]byte{
+		"user.first":  []byte("1"),
+		"user.second": {0x00, 0xff, 0x10},
+	}
+	if err := SetAllXattr(file, xattrs); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := getAllXattr(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range xattrs {
+		if !bytes.Equal(found[k], v) {
+			t.Fatalf("Expected extended attribute %s to be %q. Found %q instead.", k, v, found[k])
+		}
+	}
+
+	fdfile := filepath.Join(dir, "fdfile")
+	f, err := os.Create(fdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = SetAllXattrFd(int(f.Fd()), xattrs); err != nil {
+		t.Fatal(err)
+	}
+	found, err = getAllXattr(fdfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(xattrs) {
+		t.Fatalf("Expected %d extended attributes. Found %d instead.", len(xattrs), len(found))
+	}
+
+	// The user namespace is not permitted on symbolic links.
+	link := filepath.Join(dir, "link")
+	if err = os.Symlink(file, link); err != nil {
+		t.Fatal(err)
+	}
+
+	err = SetAllXattr(link, xattrs)
+	if err == nil {
+		t.Fatalf("Expected setting user extended attributes on a symbolic link to fail.")
+	}
+	if _, ok := err.(MultiError); ok {
+		t.Fatalf("Expected to stop at the first error. Received %v instead.", err)
+	}
+
+	err = SetAllXattr(link, xattrs, WithContinueOnXattrErrors())
+	m, ok := err.(MultiError)
+	if !ok || len(m) != len(xattrs) {
+		t.Fatalf("Expected a MultiError with %d errors. Received %v instead.", len(xattrs), err)
+	}
+
+	found, err = getAllXattr(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(xattrs) {
+		t.Fatalf("Expected the target of the symbolic link to be unchanged.")
+	}
+}
+
+func TestRemoveAllXattrOnOverwrite(t *testing.T) {
+	archive := writeTestArchive(t, []testEntry{
+		{header: &tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0755, Xattrs: map[string]string{"user.archive": "kept"}}},
+	})
+
+	dest := t.TempDir()
+	dir := filepath.Join(dest, "dir")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Setxattr(dir, "user.stale", []byte("removed"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Extract(archive, dest, WithOverwrite(OverwriteReplace)); err != nil {
+		t.Fatal(err)
+	}
+	found, err := getAllXattr(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := found["user.stale"]; !ok {
+		t.Fatalf("Expected user.stale to be kept without WithCleanXattrsOnOverwrite().")
+	}
+
+	if err = Extract(archive, dest, WithOverwrite(OverwriteReplace), WithCleanXattrsOnOverwrite()); err != nil {
+		t.Fatal(err)
+	}
+	found, err = getAllXattr(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || string(found["user.archive"]) != "kept" {
+		t.Fatalf("Expected only user.archive to be present. Found %v instead.", found)
+	}
+
+	if err = RemoveAllXattr(dir); err != nil {
+		t.Fatal(err)
+	}
+	found, err = getAllXattr(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 0 {
+		t.Fatalf("Expected all extended attributes to be removed. Found %v instead.", found)
+	}
+}
+
+func TestGetXattr(t *testing.T) {
+	for k, v := range testxattr {
+		value, err := GetXattr(prefix+entries[6], k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(value) != v {
+			t.Fatalf("Expected extended attribute %s to be %q. Found %q instead.", k, v, value)
+		}
+	}
+
+	if _, err := GetXattr(prefix+entries[6], "user.missing"); !errors.Is(err, unix.ENODATA) {
+		t.Fatalf("Expected ENODATA. Received %v instead.", err)
+	}
+}
+
+func TestSetXattr(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	value := []byte{0x00, 0xff, 0x10}
+	if err := SetXattr(file, "user.test", value); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := GetXattr(file, "user.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(found, value) {
+		t.Fatalf("Expected extended attribute user.test to be %q. Found %q instead.", value, found)
+	}
+
+	if err = SetXattr(file, "invalid.test", value); !errors.Is(err, unix.ENOTSUP) {
+		t.Fatalf("Expected ENOTSUP. Received %v instead.", err)
+	}
+}
+
+func TestCreateXattrProgress(t *testing.T) {
+	src := t.TempDir()
+	xattrs := map[string]map[string][]byte{
+		"none": nil,
+		"one":  {"user.a": []byte("a")},
+		"two":  {"user.a": []byte("a"), "user.b": []byte("b")},
+	}
+	for name, x := range xattrs {
+		file := filepath.Join(src, name)
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetAllXattr(file, x); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts := make(map[string]int)
+	calls := 0
+	fn := func(path string, xattrCount int) {
+		calls++
+		counts[filepath.Base(path)] = xattrCount
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	if err := Create(tarball, src, src, WithXattrProgress(fn)); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != len(xattrs) {
+		t.Fatalf("Expected %d calls. Received %d instead.", len(xattrs), calls)
+	}
+	for name, x := range xattrs {
+		if counts[name] != len(x) {
+			t.Fatalf("Expected %d extended attributes for %s. Received %d instead.", len(x), name, counts[name])
+		}
+	}
+}
+
+func TestSetAllXattrSizeLimit(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 100*1024)
+	xattrs := map[string][]byte{
+		"user.large": large,
+		"user.small": []byte("small"),
+	}
+	limit := 1024
+
+	newFile := func() string {
+		file := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	file := newFile()
+	err := SetAllXattr(file, xattrs, WithXattrSizeLimit(limit))
+	if !errors.Is(err, ErrXattrTooLarge) {
+		t.Fatalf("Expected ErrXattrTooLarge. Received %v instead.", err)
+	}
+
+	file = newFile()
+	if err = SetAllXattr(file, xattrs, WithXattrSizeLimit(limit), WithXattrOversizePolicy(OversizeTruncate)); err != nil {
+		t.Fatal(err)
+	}
+	found, err := getAllXattr(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(found["user.large"], large[:limit]) || string(found["user.small"]) != "small" {
+		t.Fatalf("Expected user.large to be truncated to %d bytes. Found %d bytes instead.", limit, len(found["user.large"]))
+	}
+
+	file = newFile()
+	if err = SetAllXattr(file, xattrs, WithXattrSizeLimit(limit), WithXattrOversizePolicy(OversizeSkip)); err != nil {
+		t.Fatal(err)
+	}
+	found, err = getAllXattr(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := found["user.large"]; ok || string(found["user.small"]) != "small" {
+		t.Fatalf("Expected only user.small to be set. Found %v instead.", found)
+	}
+}
+
+func TestXattrUnsupported(t *testing.T) {
+	// procfs does not support user extended attributes.
+	path := "/proc/self/status"
+	xattrs := map[string][]byte{"user.test": []byte("value")}
+
+	err := SetAllXattr(path, xattrs)
+	var unsupported *XattrUnsupportedError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("Expected a XattrUnsupportedError. Received %v instead.", err)
+	}
+	if unsupported.Path != path || !errors.Is(err, unix.ENOTSUP) {
+		t.Fatalf("Expected ENOTSUP for %s. Received %v instead.", path, err)
+	}
+
+	if err = SetAllXattr(path, xattrs, WithSkipUnsupportedXattr()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = unsupportedXattr(path, unix.EPERM); err != unix.EPERM {
+		t.Fatalf("Expected EPERM to be returned as is. Received %v instead.", err)
+	}
+}
+
+func TestBatchSetXattr(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	xattrs := make(map[string][]byte)
+	for i := 0; i < 10; i++ {
+		xattrs["user.attr"+string(rune('0'+i))] = []byte{byte(i)}
+	}
+	if err := BatchSetXattr(file, xattrs); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := getAllXattr(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range xattrs {
+		if !bytes.Equal(found[k], v) {
+			t.Fatalf("Expected extended attribute %s to be %q. Found %q instead.", k, v, found[k])
+		}
+	}
+}
+
+func TestBatchSetXattrFd(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	xattrs := map[string][]byte{"user.first": []byte("1"), "user.second": []byte("2")}
+	if err = BatchSetXattrFd(int(f.Fd()), xattrs); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := getAllXattr(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range xattrs {
+		if !bytes.Equal(found[k], v) {
+			t.Fatalf("Expected extended attribute %s to be %q. Found %q instead.", k, v, found[k])
+		}
+	}
+}
+
+func TestBatchSetXattrUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Files are always readable for root.")
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0200); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := BatchSetXattr(file, map[string][]byte{"user.test": []byte("value")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading extended attributes requires read permission.
+	if err := os.Chmod(file, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := GetXattr(file, "user.test"); err != nil || string(value) != "value" {
+		t.Fatalf("Expected extended attribute user.test to be value. Found %q (%v) instead.", value, err)
+	}
+}
+
+func benchmarkSetXattr(b *testing.B, set func(string, map[string][]byte, ...Option) error) {
+	file := filepath.Join(b.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		b.Fatal(err)
+	}
+
+	xattrs := make(map[string][]byte)
+	for i := 0; i < 16; i++ {
+		xattrs["user.attr"+string(rune('a'+i))] = bytes.Repeat([]byte{byte(i)}, 64)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := set(file, xattrs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Run with strace -c -f to compare the path lookups of both functions.
+func BenchmarkSetAllXattr(b *testing.B) {
+	benchmarkSetXattr(b, SetAllXattr)
+}
+
+func BenchmarkBatchSetXattr(b *testing.B) {
+	benchmarkSetXattr(b, BatchSetXattr)
+}
+
+func TestListXattrs(t *testing.T) {
+	names, err := ListXattrs(prefix + entries[6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != len(testxattr) {
+		t.Fatalf("Expected %d extended attributes. Received %v instead.", len(testxattr), names)
+	}
+	for _, name := range names {
+		if _, ok := testxattr[name]; !ok {
+			t.Fatalf("Found unexpected extended attribute %s.", name)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err = os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if names, err = ListXattrs(file); err != nil || names != nil {
+		t.Fatalf("Expected no extended attributes. Received %v (%v) instead.", names, err)
+	}
+
+	tests := map[string][]string{
+		"":                     nil,
+		"user.a\x00":           {"user.a"},
+		"user.a":               {"user.a"},
+		"user.a\x00user.b\x00": {"user.a", "user.b"},
+		"user.a\x00security.b": {"user.a", "security.b"},
+	}
+	for list, expected := range tests {
+		if names := parseXattrList([]byte(list)); !reflect.DeepEqual(names, expected) {
+			t.Fatalf("Expected names %v for %q. Received %v instead.", expected, list, names)
+		}
+	}
+}
+
+func TestGetAllXattrWithProgress(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	xattrs := make(map[string][]byte)
+	for i := 0; i < 100; i++ {
+		// ext4 stores all extended attributes of a file in a single
+		// block so names and values are kept short.
+		xattrs[fmt.Sprintf("user.%02d", i)] = bytes.Repeat([]byte("x"), i%4+1)
+	}
+	if err := SetAllXattr(file, xattrs); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := make(map[string]int)
+	found, err := GetAllXattrWithProgress(file, func(attrName string, bytesRead int) {
+		if bytesRead != len(xattrs[attrName]) {
+			t.Fatalf("Expected %d bytes for %s. Received %d instead.", len(xattrs[attrName]), attrName, bytesRead)
+		}
+		calls[attrName]++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != len(xattrs) || len(calls) != len(xattrs) {
+		t.Fatalf("Expected %d extended attributes and callbacks. Received %d and %d instead.", len(xattrs), len(found), len(calls))
+	}
+	for name, n := range calls {
+		if n != 1 {
+			t.Fatalf("Expected a single callback for %s. Received %d instead.", name, n)
+		}
+	}
+}
+
+func TestGetXattrsByNamespace(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Setting trusted extended attributes requires root.")
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	xattrs := map[string][]byte{
+		"user.checksum": []byte("sum"),
+		"user.origin":   []byte("origin"),
+		"trusted.label": []byte("label"),
+	}
+	if err := SetAllXattr(file, xattrs); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := GetXattrsByNamespace(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[string][]byte{
+		"user":    {"checksum": []byte("sum"), "origin": []byte("origin")},
+		"trusted": {"label": []byte("label")},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Fatalf("Expected %q. Received %q instead.", expected, groups)
+	}
+
+	user, err := GetXattrNamespace(file, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(user, expected["user"]) {
+		t.Fatalf("Expected %q. Received %q instead.", expected["user"], user)
+	}
+
+	security, err := GetXattrNamespace(file, "security")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(security) != 0 {
+		t.Fatalf("Expected no security extended attributes. Received %q instead.", security)
+	}
+}
+
+func TestGetXattrsByNamespaceSymlink(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("Setting trusted extended attributes requires root.")
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetXattr(file, "trusted.label", []byte("file")); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink("file", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Lsetxattr(link, "trusted.label", []byte("link"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := GetXattrsByNamespace(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := string(groups["trusted"]["label"]); value != "link" {
+		t.Fatalf("Expected the attribute of the link itself. Received %q instead.", value)
+	}
+
+	trusted, err := GetXattrNamespace(link, "trusted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := string(trusted["label"]); value != "link" {
+		t.Fatalf("Expected the attribute of the link itself. Received %q instead.", value)
+	}
+}
+
+func TestXattrValueEncoding(t *testing.T) {
+	src := t.TempDir()
+	file := filepath.Join(src, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	value := []byte{0x00, 0xff, 0xfe, 0x10}
+	if err := SetXattr(file, "user.binary", value); err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive)
+	err := Create(tarball, src, src, WithXattrValueEncoding(XattrEncodingRaw))
+	if !errors.Is(err, ErrXattrNotUTF8) {
+		t.Fatalf("Expected ErrXattrNotUTF8 for a raw binary value. Received %v instead.", err)
+	}
+
+	for enc, stored := range map[XattrEncoding]string{
+		XattrEncodingBase64: "AP_-EA==",
+		XattrEncodingHex:    "00fffe10",
+	} {
+		tarball := filepath.Join(t.TempDir(), archive)
+		if err := Create(tarball, src, src, WithXattrValueEncoding(enc)); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(tarball)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := tar.NewReader(f)
+		for {
+			h, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Name == "file" {
+				if h.Xattrs["user.binary"] != stored {
+					t.Fatalf("Expected %s to store %q. Found %q instead.", enc, stored, h.Xattrs["user.binary"])
+				}
+				if h.PAXRecords[paxXattrEncoding] != enc.String() {
+					t.Fatalf("Expected the entry to record %s. Found %q instead.", enc, h.PAXRecords[paxXattrEncoding])
+				}
+				break
+			}
+		}
+		f.Close()
+
+		dest := t.TempDir()
+		if err = Extract(tarball, dest); err != nil {
+			t.Fatal(err)
+		}
+		found, err := GetXattr(filepath.Join(dest, "file"), "user.binary")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(found, value) {
+			t.Fatalf("Expected %s to restore %q. Found %q instead.", enc, value, found)
+		}
+	}
+}
+
+func TestMergeXattr(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		base     map[string][]byte
+		override map[string][]byte
+		expected map[string][]byte
+	}{
+		{"both nil", nil, nil, map[string][]byte{}},
+		{"empty override", map[string][]byte{"user.a": []byte("1")}, nil, map[string][]byte{"user.a": []byte("1")}},
+		{"empty base", nil, map[string][]byte{"user.a": []byte("1")}, map[string][]byte{"user.a": []byte("1")}},
+		{"disjoint",
+			map[string][]byte{"user.a": []byte("1")},
+			map[string][]byte{"user.b": []byte("2")},
+			map[string][]byte{"user.a": []byte("1"), "user.b": []byte("2")}},
+		{"override wins",
+			map[string][]byte{"user.a": []byte("1"), "user.b": []byte("2")},
+			map[string][]byte{"user.a": []byte("3")},
+			map[string][]byte{"user.a": []byte("3"), "user.b": []byte("2")}},
+		{"nil deletes",
+			map[string][]byte{"user.a": []byte("1"), "user.b": []byte("2")},
+			map[string][]byte{"user.a": nil},
+			map[string][]byte{"user.b": []byte("2")}},
+		{"nil deletes absent key",
+			map[string][]byte{"user.a": []byte("1")},
+			map[string][]byte{"user.b": nil},
+			map[string][]byte{"user.a": []byte("1")}},
+		{"empty value kept",
+			map[string][]byte{"user.a": []byte("1")},
+			map[string][]byte{"user.a": {}},
+			map[string][]byte{"user.a": {}}},
+	} {
+		baseLen, overrideLen := len(tc.base), len(tc.override)
+
+		merged := MergeXattr(tc.base, tc.override)
+		if !reflect.DeepEqual(merged, tc.expected) {
+			t.Fatalf("%s: Expected %q. Received %q instead.", tc.name, tc.expected, merged)
+		}
+		if len(tc.base) != baseLen || len(tc.override) != overrideLen {
+			t.Fatalf("%s: Expected the inputs to be left untouched.", tc.name)
+		}
+	}
+
+	base := map[string][]byte{"user.a": []byte("1")}
+	MergeXattr(base, nil)["user.b"] = []byte("2")
+	if _, ok := base["user.b"]; ok {
+		t.Fatalf("Expected MergeXattr to return a new map.")
+	}
+}
+
+func TestGetAllXattrFromFd(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetXattr(file, "user.a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// The attributes belong to the opened file even once path refers to
+	// another one.
+	if err = os.Rename(file, file+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	xattrs, err := GetAllXattrFromFd(f.Fd())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string][]byte{"user.a": []byte("1")}; !reflect.DeepEqual(xattrs, expected) {
+		t.Fatalf("Expected %q. Received %q instead.", expected, xattrs)
+	}
+}
+
+func TestCreateConcurrentXattrChanges(t *testing.T) {
+	src := t.TempDir()
+	file := filepath.Join(src, "file")
+	if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	values := [][]byte{[]byte("1"), bytes.Repeat([]byte("2"), 64)}
+	if err := SetXattr(file, "user.a", values[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			unix.Setxattr(file, "user.a", values[i%2], 0)
+		}
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+	}()
+
+	for i := 0; i < 50; i++ {
+		tarball := filepath.Join(t.TempDir(), archive)
+		if err := Create(tarball, src, src, WithXattrValueEncoding(XattrEncodingRaw)); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(tarball)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := tar.NewReader(f)
+		for {
+			h, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.Name != "file" {
+				continue
+			}
+			if v := h.Xattrs["user.a"]; v != string(values[0]) && v != string(values[1]) {
+				t.Fatalf("Expected a consistent value of user.a. Received %q instead.", v)
+			}
+			break
+		}
+		f.Close()
+	}
+}
@@ -0,0 +1,119 @@
+package tarski
+
+import (
+	"archive/tar"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"unicode/utf8"
+)
+
+// paxXattrEncoding is the PAX record announcing the encoding of extended
+// attribute values. It is set on every entry carrying extended attributes and
+// is also honoured in global headers.
+const paxXattrEncoding = "tarski.xattr.encoding"
+
+// XattrEncoding selects how extended attribute values are stored in PAX
+// records.
+type XattrEncoding int
+
+const (
+	// XattrEncodingBase64 stores values encoded as base64url. This is the
+	// default.
+	XattrEncodingBase64 XattrEncoding = iota
+	// XattrEncodingRaw stores values as is. PAX records have to be UTF-8 so
+	// archive creation fails with ErrXattrNotUTF8 for binary values.
+	XattrEncodingRaw
+	// XattrEncodingHex stores values hex encoded.
+	XattrEncodingHex
+)
+
+func (enc XattrEncoding) String() string {
+	switch enc {
+	case XattrEncodingRaw:
+		return "raw"
+	case XattrEncodingBase64:
+		return "base64"
+	case XattrEncodingHex:
+		return "hex"
+	}
+
+	return fmt.Sprintf("XattrEncoding(%d)", int(enc))
+}
+
+// parseXattrEncoding parses the value of the tarski.xattr.encoding record.
+func parseXattrEncoding(s string) (XattrEncoding, error) {
+	for _, enc := range []XattrEncoding{XattrEncodingRaw, XattrEncodingBase64, XattrEncodingHex} {
+		if enc.String() == s {
+			return enc, nil
+		}
+	}
+
+	return XattrEncodingRaw, fmt.Errorf("Unknown extended attribute encoding %q.", s)
+}
+
+// encode returns xattrs with all values encoded with enc. Raw values that are
+// not valid UTF-8 return ErrXattrNotUTF8.
+func (enc XattrEncoding) encode(xattrs map[string]string) (map[string]string, error) {
+	if len(xattrs) == 0 {
+		return xattrs, nil
+	}
+
+	if enc == XattrEncodingRaw {
+		for k, v := range xattrs {
+			if !utf8.ValidString(v) {
+				return nil, fmt.Errorf("%s: %w", k, ErrXattrNotUTF8)
+			}
+		}
+		return xattrs, nil
+	}
+
+	encoded := make(map[string]string, len(xattrs))
+	for k, v := range xattrs {
+		if enc == XattrEncodingHex {
+			encoded[k] = hex.EncodeToString([]byte(v))
+		} else {
+			encoded[k] = base64.URLEncoding.EncodeToString([]byte(v))
+		}
+	}
+
+	return encoded, nil
+}
+
+// encodeHeader encodes the extended attributes of h with enc and records enc
+// in a PAX record of the entry so extraction knows how to decode them.
+func (enc XattrEncoding) encodeHeader(h *tar.Header) (err error) {
+	if h.Xattrs, err = enc.encode(h.Xattrs); err != nil || len(h.Xattrs) == 0 || enc == XattrEncodingRaw {
+		return
+	}
+
+	if h.PAXRecords == nil {
+		h.PAXRecords = make(map[string]string)
+	}
+	h.PAXRecords[paxXattrEncoding] = enc.String()
+	return
+}
+
+// decode returns xattrs with all values decoded from enc.
+func (enc XattrEncoding) decode(xattrs map[string]string) (map[string]string, error) {
+	if enc == XattrEncodingRaw || len(xattrs) == 0 {
+		return xattrs, nil
+	}
+
+	decoded := make(map[string]string, len(xattrs))
+	for k, v := range xattrs {
+		var b []byte
+		var err error
+		if enc == XattrEncodingHex {
+			b, err = hex.DecodeString(v)
+		} else {
+			b, err = base64.URLEncoding.DecodeString(v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		decoded[k] = string(b)
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,27 @@
+package tarski
+
+// CreateXZ creates a xz compressed tar archive.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateXZ(archive string, path string, prefix string, opts ...Option) error {
+	return Create(archive, path, prefix, withCompression(CompressionXZ, opts)...)
+}
+
+// CreateXZSHA256 creates a xz compressed tar archive and returns the
+// SHA256-hash checksum of the uncompressed tar stream.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateXZSHA256(archive string, path string, prefix string, opts ...Option) ([]byte, error) {
+	return CreateSHA256(archive, path, prefix, withCompression(CompressionXZ, opts)...)
+}
+
+// ExtractXZ extracts a xz compressed tar archive under path.
+func ExtractXZ(archive string, path string, opts ...Option) error {
+	return Extract(archive, path, withCompression(CompressionXZ, opts)...)
+}
+
+// ExtractXZSHA256 extracts a xz compressed tar archive under path and
+// returns the SHA256-hash checksum of the uncompressed tar stream.
+func ExtractXZSHA256(archive string, path string, opts ...Option) ([]byte, error) {
+	return ExtractSHA256(archive, path, withCompression(CompressionXZ, opts)...)
+}
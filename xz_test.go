@@ -0,0 +1,76 @@
+package tarski
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestXZ(t *testing.T) {
+	uncompressed := filepath.Join(t.TempDir(), archive)
+	expected, err := CreateSHA256(uncompressed, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive+".xz")
+	if err = CreateXZ(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := IsXZ(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Expected a xz compressed archive.")
+	}
+
+	dest := t.TempDir()
+	if err = ExtractXZ(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dest, entries[6])); err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := CreateXZSHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected the checksum %x of the uncompressed stream. Received %x instead.", expected, checksum)
+	}
+
+	checksum, err = ExtractXZSHA256(tarball, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected the checksum %x of the uncompressed stream. Received %x instead.", expected, checksum)
+	}
+
+	if err = ExtractXZ(uncompressed, t.TempDir()); err == nil {
+		t.Fatal("Expected extracting an uncompressed archive with ExtractXZ to fail.")
+	}
+}
+
+func TestXZOptions(t *testing.T) {
+	for _, level := range []int{0, 1, 9} {
+		tarball := filepath.Join(t.TempDir(), archive+".xz")
+		if err := CreateXZ(tarball, prefix, prefix, WithXZOptions(XZOptions{Level: level})); err != nil {
+			t.Fatal(err)
+		}
+		if err := ExtractXZ(tarball, t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, level := range []int{-1, 10} {
+		tarball := filepath.Join(t.TempDir(), archive+".xz")
+		if err := CreateXZ(tarball, prefix, prefix, WithXZOptions(XZOptions{Level: level})); !errors.Is(err, ErrInvalidXZLevel) {
+			t.Fatalf("Expected ErrInvalidXZLevel for level %d. Received %v instead.", level, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package tarski
+
+// CreateZstd creates a zstd compressed tar archive.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateZstd(archive string, path string, prefix string, opts ...Option) error {
+	return Create(archive, path, prefix, withCompression(CompressionZstd, opts)...)
+}
+
+// CreateZstdSHA256 creates a zstd compressed tar archive and returns the
+// SHA256-hash checksum of the uncompressed tar stream.
+// The string given by prefix will be stripped from all entries found under
+// path.
+func CreateZstdSHA256(archive string, path string, prefix string, opts ...Option) ([]byte, error) {
+	return CreateSHA256(archive, path, prefix, withCompression(CompressionZstd, opts)...)
+}
+
+// ExtractZstd extracts a zstd compressed tar archive under path.
+func ExtractZstd(archive string, path string, opts ...Option) error {
+	return Extract(archive, path, withCompression(CompressionZstd, opts)...)
+}
+
+// ExtractZstdSHA256 extracts a zstd compressed tar archive under path and
+// returns the SHA256-hash checksum of the uncompressed tar stream.
+func ExtractZstdSHA256(archive string, path string, opts ...Option) ([]byte, error) {
+	return ExtractSHA256(archive, path, withCompression(CompressionZstd, opts)...)
+}
@@ -0,0 +1,79 @@
+package tarski
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZstd(t *testing.T) {
+	uncompressed := filepath.Join(t.TempDir(), archive)
+	expected, err := CreateSHA256(uncompressed, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tarball := filepath.Join(t.TempDir(), archive+".zst")
+	if err = CreateZstd(tarball, prefix, prefix); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := IsZstd(tarball)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Expected a zstd compressed archive.")
+	}
+
+	dest := t.TempDir()
+	if err = ExtractZstd(tarball, dest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dest, entries[6])); err != nil {
+		t.Fatal(err)
+	}
+
+	checksum, err := CreateZstdSHA256(tarball, prefix, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected the checksum %x of the uncompressed stream. Received %x instead.", expected, checksum)
+	}
+
+	checksum, err = ExtractZstdSHA256(tarball, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(checksum, expected) {
+		t.Fatalf("Expected the checksum %x of the uncompressed stream. Received %x instead.", expected, checksum)
+	}
+
+	if err = ExtractZstd(uncompressed, t.TempDir()); err == nil {
+		t.Fatal("Expected extracting an uncompressed archive with ExtractZstd to fail.")
+	}
+}
+
+func TestZstdOptions(t *testing.T) {
+	var sizes []int64
+	for _, z := range []ZstdOptions{{Level: 1, Threads: 1}, {Level: 19, Threads: 2}} {
+		tarball := filepath.Join(t.TempDir(), archive+".zst")
+		if err := CreateZstd(tarball, prefix, prefix, WithZstdOptions(z)); err != nil {
+			t.Fatal(err)
+		}
+		if err := ExtractZstd(tarball, t.TempDir()); err != nil {
+			t.Fatal(err)
+		}
+
+		fi, err := os.Stat(tarball)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, fi.Size())
+	}
+
+	if sizes[1] > sizes[0] {
+		t.Fatalf("Expected level 19 to compress at least as well as level 1. Received %d and %d bytes instead.", sizes[1], sizes[0])
+	}
+}